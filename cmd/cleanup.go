@@ -0,0 +1,141 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/amazeeio/lagoon-restore-files-task/internal/task"
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// taskResource is a resource of a run of the task, named after the task key.
+type taskResource struct {
+	kind string
+	obj  client.Object
+}
+
+// taskResources are the resources of a run of the task with the task key.
+func taskResources(t *task.RestoreTask, key string) []taskResource {
+	resources := []taskResource{
+		{"pod", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("inspect-%s", key)}}},
+		{"pod", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("upload-%s", key)}}},
+		{"pod", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("list-%s", key)}}},
+		{"pod", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("preview-%s", key)}}},
+		{"pod", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("host-%s", key)}}},
+		{"pod", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("validate-%s", key)}}},
+		{"pod", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("unlock-%s", key)}}},
+		{"pod", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("check-%s", key)}}},
+		{"pod", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("size-%s", key)}}},
+		{"restore", &k8upv1.Restore{ObjectMeta: metav1.ObjectMeta{Name: key}}},
+		{"pvc", &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("restore-target-%s", key)}}},
+		{"restore", &k8upv1.Restore{ObjectMeta: metav1.ObjectMeta{Name: key + "-diff"}}},
+		{"pvc", &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("restore-target-%s-diff", key)}}},
+		{"pvc", &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("archive-target-%s", key)}}},
+	}
+	for i := range t.Args.MergeBackupIds {
+		resources = append(resources, taskResource{"restore", &k8upv1.Restore{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-m%d", key, i+1)}}})
+	}
+	return resources
+}
+
+// retryAttempts finds the attempts of `-retry` which left resources behind. Retries run with the
+// task key `{key}-r{attempt}`, and how many ran is not known after the task.
+func retryAttempts(t *task.RestoreTask) ([]int, error) {
+	pattern := regexp.MustCompile(`(?:^|-)` + regexp.QuoteMeta(t.TaskKey) + `-r([0-9]+)(?:-|$)`)
+
+	var names []string
+	var pods corev1.PodList
+	if err := t.Client.List(t.Ctx, &pods); err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		names = append(names, pod.Name)
+	}
+	var pvcs corev1.PersistentVolumeClaimList
+	if err := t.Client.List(t.Ctx, &pvcs); err != nil {
+		return nil, fmt.Errorf("failed to list pvcs: %w", err)
+	}
+	for _, pvc := range pvcs.Items {
+		names = append(names, pvc.Name)
+	}
+	var restores k8upv1.RestoreList
+	if err := t.Client.List(t.Ctx, &restores); err != nil {
+		return nil, fmt.Errorf("failed to list restores: %w", err)
+	}
+	for _, restore := range restores.Items {
+		names = append(names, restore.Name)
+	}
+
+	seen := map[int]bool{}
+	var attempts []int
+	for _, name := range names {
+		match := pattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		attempt, err := strconv.Atoi(match[1])
+		if err != nil || seen[attempt] {
+			continue
+		}
+		seen[attempt] = true
+		attempts = append(attempts, attempt)
+	}
+	sort.Ints(attempts)
+	return attempts, nil
+}
+
+// CleanupTask removes all resources a run of the task may have left behind, eg an inspect pod or a
+// kept archive PVC, including those of retry attempts.
+func CleanupTask(t *task.RestoreTask) error {
+	resources := taskResources(t, t.TaskKey)
+	attempts, err := retryAttempts(t)
+	if err != nil {
+		log.Printf("Warning: failed to find resources of retry attempts: %v", err)
+	}
+	for _, attempt := range attempts {
+		resources = append(resources, taskResources(t, fmt.Sprintf("%s-r%d", t.TaskKey, attempt))...)
+	}
+
+	var failed int
+	for _, r := range resources {
+		err := t.Client.Delete(t.Ctx, r.obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to remove %s %s: %v", r.kind, r.obj.GetName(), err)
+			failed++
+			continue
+		}
+		log.Printf("Removed %s %s", r.kind, r.obj.GetName())
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %d resources", failed)
+	}
+
+	return nil
+}
@@ -17,20 +17,33 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/amazeeio/lagoon-restore-files-task/internal/task"
+	"github.com/dustin/go-humanize"
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 func Execute() {
 	// Load advanced task arguments from JSON_PAYLOAD env var.
-	var backupIdArg, restoreFilterArg string
+	var backupIdArg, snapshotIdArg, restoreFilterArg, descriptionArg string
+	var mergeBackupIdsArg []string
 	if jsonPayloadEnc := os.Getenv("JSON_PAYLOAD"); jsonPayloadEnc != "" {
 		jsonPayload, err := base64.StdEncoding.DecodeString(jsonPayloadEnc)
 		if err == nil {
@@ -38,12 +51,19 @@ func Execute() {
 			err := json.Unmarshal(jsonPayload, &taskArgs)
 			if err == nil {
 				backupIdArg = taskArgs.BackupId
+				snapshotIdArg = taskArgs.SnapshotId
+				mergeBackupIdsArg = taskArgs.MergeBackupIds
 				restoreFilterArg = taskArgs.RestoreFilter
+				descriptionArg = taskArgs.Description
 			}
 		}
 	}
 	taskNamespaceEnv := os.Getenv("NAMESPACE")
 	taskIdEnv := os.Getenv("TASK_DATA_ID")
+	protectedNamespacesEnv := os.Getenv("PROTECTED_NAMESPACES")
+	if protectedNamespacesEnv == "" {
+		protectedNamespacesEnv = task.DefaultProtectedNamespaces
+	}
 	tokenHostEnv := os.Getenv("LAGOON_CONFIG_TOKEN_HOST")
 	if tokenHostEnv == "" {
 		tokenHostEnv = os.Getenv("TASK_SSH_HOST")
@@ -52,39 +72,215 @@ func Execute() {
 	if tokenPortEnv == "" {
 		tokenPortEnv = os.Getenv("TASK_SSH_PORT")
 	}
+	sshKeyEnv := os.Getenv("LAGOON_SSH_KEY_PATH")
+	if sshKeyEnv == "" {
+		sshKeyEnv = task.DefaultSSHKeyPath
+	}
 	apiHostEnv := os.Getenv("LAGOON_CONFIG_API_HOST")
 	if apiHostEnv == "" {
 		apiHostEnv = os.Getenv("TASK_API_HOST")
 	}
+	otlpEndpointEnv := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
 
 	// CLI flags for local development.
 	kubeconfig := flag.String("kubeconfig", "", "Absolute path to a kubeconfig file")
 	taskNamespace := flag.String("ns", taskNamespaceEnv, "Environment namespace")
+	restoreNamespace := flag.String("restore-namespace", "", "Namespace to restore into instead of the environment namespace, eg for DR drills")
+	createRestoreNamespace := flag.Bool("create-restore-namespace", false, "Create the restore namespace if it does not exist, and remove it after the task")
+	protectedNamespaces := flag.String("protected-namespaces", protectedNamespacesEnv, "Comma separated namespaces the task refuses to run in, * matches any characters")
 	taskId := flag.String("tid", taskIdEnv, "Task ID")
 	backupId := flag.String("bid", backupIdArg, "Backup ID")
 	restoreFilter := flag.String("filter", restoreFilterArg, "Restore filter")
-	restoreTarget := flag.String("restore-target", "/restore", "Path to restored files")
-	archiveTarget := flag.String("archive-target", "/archive", "Path to archive of restored files")
+	volume := flag.String("volume", "", "Restore from this volume, the restore filter is a path within the volume")
+	var volumeMap stringSlice
+	flag.Var(&volumeMap, "volume-map", "Restic path of a volume as NAME=PATH, defaults to /data/NAME, can be repeated")
+	description := flag.String("description", descriptionArg, "Description of the restore added to the archive info file")
+	restoreTarget := flag.String("restore-target", "/restore", "Path to restored files, can be a template with {{.Namespace}}, {{.TaskID}}, {{.Snapshot}} and {{env \"NAME\"}}")
+	archiveTarget := flag.String("archive-target", "/archive", "Path to archive of restored files, can be a template like -restore-target")
 	tokenHost := flag.String("token-host", tokenHostEnv, "SSH token host")
 	tokenPort := flag.String("token-port", tokenPortEnv, "SSH token port")
 	apiHost := flag.String("api-host", apiHostEnv, "Lagoon API host")
+	sshKey := flag.String("ssh-key", sshKeyEnv, "Path to the SSH private key used to get a Lagoon token")
+	resourcePrefix := flag.String("resource-prefix", task.DefaultResourcePrefix, "Prefix of the names of all resources created by the task")
 	taskImage := flag.String("task-image", "", "Task image")
+	uploadImage := flag.String("upload-image", "", "Image of the upload pod, defaults to the task image")
 	skipBootstrap := flag.Bool("skip-bootstrap", false, "Skip bootstrap upload pod")
+	logFormat := flag.String("log-format", task.LogFormatText, "Format of the log: text, or json for a JSON object per line with the task ID, backup ID and phase")
+	logSections := flag.Bool("log-sections", false, "Mark the phases of the task in the log with ::section::NAME lines and end it with a ::summary:: line of the result, for the Lagoon UI")
+	completionMarker := flag.String("completion-marker", "", "Path to write a JSON marker with the outcome to when the task finishes, for orchestrators")
+	output := flag.String("output", "text", "Output format of the task result: text or json")
+	onEmpty := flag.String("on-empty", task.OnEmptyFail, "Behaviour when the restore filter matches no files: fail, warn or skip-upload")
+	inPlace := flag.String("in-place", "", "Restore directly into the PVC of this deployment instead of uploading an archive")
+	confirmInPlace := flag.Bool("confirm-in-place", false, "Confirm that an in-place restore may overwrite live files")
+	scaleDown := flag.Bool("scale-down", false, "Scale the in-place deployment down while restoring")
+	scaleDownTimeout := flag.Duration("scale-down-timeout", task.DefaultScaleDownTimeout, "How long to wait for the pods of the in-place deployment to stop with -scale-down")
+	var resticEnv stringSlice
+	flag.Var(&resticEnv, "restic-env", "Additional RESTIC_* env var for the restore job as KEY=VALUE, can be repeated")
+	var resticArgs stringSlice
+	flag.Var(&resticArgs, "restic-arg", `Additional restic option for the restore job as "-o KEY=VALUE", can be repeated, ignored when the k8up operator sets RESTIC_OPTIONS`)
+	var pvcAnnotations, requiredPVCAnnotations stringSlice
+	flag.Var(&pvcAnnotations, "pvc-annotation", `Annotation of the restore and archive PVCs as KEY=VALUE, the value can be a template like {{env "LAGOON_PROJECT"}}, can be repeated`)
+	flag.Var(&requiredPVCAnnotations, "require-pvc-annotation", "Annotation the restore and archive PVCs must have with a non-empty value, can be repeated")
+	resticCachePVC := flag.String("restic-cache-pvc", "", "Existing PVC keeping the restic cache of the restore job between restores from the same repository")
+	pvcTerminationTimeout := flag.Duration("pvc-termination-timeout", task.DefaultPVCTerminationTimeout, "How long to wait for a restore PVC of a previous run stuck terminating")
+	force := flag.Bool("force", false, "Remove the finalizers of a restore PVC of a previous run still terminating after -pvc-termination-timeout, if no pods mount it")
+	restoreBackoffLimit := flag.Int("restore-backoff-limit", -1, "Number of retries of the restore job before it fails, defaults to the Kubernetes default of 6")
+	restoreWorkers := flag.Int("restore-workers", 0, fmt.Sprintf("Number of parallel repository backend connections of the restore job, 1-%d, or 0 for the restic default, set through RESTIC_OPTIONS and ignored when the k8up operator sets RESTIC_OPTIONS", task.MaxRestoreWorkers))
+	uploadTimeout := flag.Duration("upload-timeout", 0, "How long the upload of the archive to the Lagoon tasks may take, unlimited when 0")
+	restoreTimeout := flag.Duration("restore-timeout", task.DefaultRestoreTimeout, "How long to wait for the restore to complete before failing and cleaning up, unlimited when 0")
+	uploadPodTimeout := flag.Duration("upload-pod-timeout", task.DefaultUploadPodTimeout, "How long to wait for the upload pod, or pods running restic, to finish before failing and cleaning up, unlimited when 0")
+	archivePVC := flag.String("archive-pvc", "", "Existing PVC to archive into, reused by every run and cleared at the start of each upload, instead of creating one per run")
+	keepArchiveOnFailure := flag.Bool("keep-archive-on-failure", false, "Keep the archive PVC when the upload fails")
+	onClosedTask := flag.String("on-closed-task", task.OnClosedTaskFail, "Behaviour when the Lagoon task no longer accepts uploads, eg because it timed out: fail or keep-archive")
+	snapshotBefore := flag.String("snapshot-before", "", "Only restore a snapshot taken before this RFC 3339 time, eg to pick the latest snapshot before an incident with -bid latest")
+	restoreDate := flag.String("restore-date", "", "Restore the latest snapshot of the restore filter taken on or before this date (2006-01-02, UTC) or RFC 3339 time, instead of a backup ID")
+	snapshotAfter := flag.String("snapshot-after", "", "Only restore a snapshot taken after this RFC 3339 time")
+	allowHostMismatch := flag.Bool("allow-host-mismatch", false, "Restore the latest snapshot even if it was taken under another restic host than the environment's, eg before it was renamed or migrated")
+	checkSnapshotHost := flag.Bool("check-snapshot-host", false, "Also warn when a snapshot restored by ID was taken under another restic host than the environment's")
+	globalS3Endpoint := flag.String("global-s3-endpoint", os.Getenv("BACKUP_GLOBALS3ENDPOINT"), "Global S3 endpoint of the k8up operator, for pods running restic when the schedule has none")
+	globalS3Bucket := flag.String("global-s3-bucket", os.Getenv("BACKUP_GLOBALS3BUCKET"), "Global S3 bucket of the k8up operator, for pods running restic when the schedule has none")
+	globalS3Secret := flag.String("global-s3-secret", "", "Secret with the global AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and RESTIC_PASSWORD of the k8up operator, for pods running restic when the schedule has none")
+	repositoryPath := flag.String("repository-path", "", "Restore from the restic repository at this path in the S3 bucket of the schedule, eg of a sibling environment")
+	symlinks := flag.String("symlinks", task.SymlinksPreserve, "Handling of symlinks when archiving: preserve, follow or skip")
+	var additionalTaskIds stringSlice
+	flag.Var(&additionalTaskIds, "additional-task-id", "Lagoon task ID to upload the archive to in addition to the task, can be repeated")
+	var encryptRecipients stringSlice
+	flag.Var(&encryptRecipients, "encrypt", "Encrypt the archive with age for this recipient public key, can be repeated")
+	strict := flag.Bool("strict", false, "Fail when the restore contains special files like devices, sockets or fifos, or a different number of files than the snapshot, instead of warning")
+	archiveFormat := flag.String("archive-format", task.ArchiveFormatTarGz, "Format of the archive: tar.gz, tar.zst, tar.xz, tar.bz2 or zip")
+	compressionLevel := flag.Int("compression-level", 6, "Compression level of the archive, 1 (fastest) to 9 (smallest), not applied to tar.xz and zip")
+	compressionThreads := flag.Int("compression-threads", 1, "Number of blocks compressed in parallel, uses about 2 x threads x block size of memory")
+	compressionBlockSize := flag.String("compression-block-size", "1MiB", "Size of the blocks compressed in parallel with -compression-threads, 64KiB to 64MiB")
+	rsyncable := flag.Bool("rsyncable", false, "Compress the archive with rsyncable gzip framing, for efficient transfers of similar archives to rsync or deduplicating stores")
+	sparseFiles := flag.String("sparse-files", task.SparseFilesWarn, "Handling of large sparse files, which are archived at full size: warn, exclude or fail")
+	caseCollisions := flag.String("case-collisions", task.CaseCollisionsWarn, "Handling of paths differing only in case, which collide when extracted on case-insensitive filesystems: warn, fail or rename")
+	var excludePaths stringSlice
+	flag.Var(&excludePaths, "exclude-path", "Leave paths matching this pattern out of the archive, /-prefixed patterns match from the snapshot root, others any path element, can be repeated")
+	var includeExtensions, excludeExtensions stringSlice
+	flag.Var(&includeExtensions, "include-ext", "Only archive files with this extension, eg sql, can be repeated")
+	flag.Var(&excludeExtensions, "exclude-ext", "Leave files with this extension out of the archive, eg log, can be repeated")
+	excludeHidden := flag.Bool("exclude-hidden", false, "Leave dotfiles and dot directories below the restore filter out of the archive")
+	verifyFileCount := flag.Bool("verify-file-count", false, "Compare the number of restored files with the number of files in the snapshot")
+	expectedFiles := flag.Int("expected-files", -1, "Number of files in the snapshot to compare the restored files with, set by -verify-file-count")
+	fileCountTolerance := flag.Int("file-count-tolerance", 0, "Percentage the restored file count may differ from the snapshot file count")
+	reproducible := flag.Bool("reproducible", false, "Create byte identical archives for the same restored files")
+	noInfoFile := flag.Bool("no-info-file", false, "Don't add RESTORE_INFO.txt to the archive")
+	reclaimRetainedPVs := flag.Bool("reclaim-retained-pvs", false, "Set the reclaim policy of PVs of the task's PVCs with the Retain policy to Delete before removing the PVCs, so their storage is released")
+	cleanupRetries := flag.Int("cleanup-retries", task.DefaultCleanupBackoff.Steps, "Attempts for removing each resource of the task on transient API errors")
+	lookupRetries := flag.Int("lookup-retries", task.DefaultLookupBackoff.Steps, "Attempts for the initial resource lookups on transient API errors")
+	diffDeployment := flag.String("diff", "", "Log the differences between the restore and the PVC of this deployment before uploading")
+	diffTarget := flag.String("diff-target", "", "Path to live files to diff the restore against")
+	diffSnapshot := flag.String("diff-snapshot", "", "Also restore this snapshot and upload a report of the changes from it to the restored snapshot")
+	diffOnly := flag.Bool("diff-only", false, "Only upload the report of -diff-snapshot, without archiving the restore")
+	reuseRestore := flag.Bool("reuse-restore", false, "Reuse a completed restore kept by a previous run of this task and only archive and upload it")
+	reuseRestoreFrom := flag.String("reuse-restore-from", "", "Reuse a completed restore kept by the task with this ID, eg a previous Lagoon task, and only archive and upload it")
+	keepRestoreOnFailure := flag.Bool("keep-restore-on-failure", false, "Keep the completed restore and its PVC when the upload fails, to upload them again with -reuse-restore")
+	priorityClass := flag.String("priority-class", "", "Priority class of the restore job and upload pod")
+	pvcSize := flag.String("pvc-size", task.DefaultPVCSize, "Requested size of the restore and archive PVCs, on block storage large enough for the uncompressed restore and the archive")
+	storageClass := flag.String("storage-class", task.DefaultStorageClass, "Storage class of the restore and archive PVCs, the cluster default when empty")
+	volumeMode := flag.String("volume-mode", string(corev1.PersistentVolumeFilesystem), "Volume mode of the restore and archive PVCs")
+	waitForBackup := flag.Bool("wait-for-backup", false, "Wait for running backups to finish before restoring, instead of only warning")
+	backupWaitTimeout := flag.Duration("backup-wait-timeout", task.DefaultBackupWaitTimeout, "How long to wait for running backups with -wait-for-backup")
+	integrityCheck := flag.Bool("integrity-check", false, "Verify restored files against SHA256SUMS manifests in the backup before archiving")
+	verifySample := flag.String("verify-sample", "", "Read back a random sample of restored files, a number of files or a percentage like 5%, to verify they are readable")
+	verifyArchive := flag.Bool("verify-archive", false, "Read the archive back to verify it is not corrupt before uploading it, this doubles the archive read cost")
+	checkReadData := flag.Int("check-read-data", 0, "Percentage of the repository data read and verified with restic check --read-data-subset after restoring, 0-100, 0 skips the check")
+	checkRestoreSize := flag.Bool("check-restore-size", false, "Sum the sizes of the snapshot files matching the restore filter before restoring, and fail when they don't fit into -pvc-size")
+	integrityCheckPercent := flag.Int("integrity-check-percent", 100, "Percentage of files listed in checksum manifests verified by -integrity-check, 1-100")
+	outputFormat := flag.String("output-format", task.OutputFormatArchive, "Format of the restored files: archive to upload a compressed archive, directory to copy them uncompressed to the archive target without uploading, or files to upload the files matching -upload-files individually")
+	uploadFiles := flag.String("upload-files", "", "Pattern of the restored files uploaded individually with -output-format files, matching the file name or, with a /, the path")
+	maxUploadFiles := flag.Int("max-upload-files", task.DefaultMaxUploadFiles, fmt.Sprintf("Maximum number of files uploaded individually with -output-format files, 1-%d", task.MaxUploadFilesLimit))
+	uploadAtomicity := flag.String("upload-atomicity", task.UploadAtomicityBestEffort, "Handling of files uploaded before another file failed to upload with -output-format files: best-effort to keep them, or all-or-nothing to remove them again")
+	restoreMethods := flag.String("restore-methods", task.RestoreMethodFolder, "Comma separated restore methods to try in order when the restore destination can't be created: folder, s3")
+	s3Endpoint := flag.String("s3-endpoint", "", "Endpoint of S3 restores, defaults to the global k8up restore endpoint")
+	s3Bucket := flag.String("s3-bucket", "", "Bucket of S3 restores, defaults to the global k8up restore bucket")
+	s3Secret := flag.String("s3-secret", "", "Secret with AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY for S3 restores, defaults to the global k8up restore credentials")
+	archiveMode := flag.String("archive-mode", "", "Octal file mode of the archive, eg 0644, defaults to the umask based mode")
+	archiveOwner := flag.String("archive-owner", "", "Owner of the archive as UID[:GID], only applied when running as root")
+	listFiles := flag.Int("list-files", task.DefaultListFiles, "Number of restored files to log before archiving, 0 to disable")
+	failFast := flag.Bool("fail-fast", false, "Stop waiting for the restore on a terminal condition reason: "+strings.Join(task.DefaultFailFastReasons, ", "))
+	var failFastReasons stringSlice
+	flag.Var(&failFastReasons, "fail-fast-reason", "Additional restore condition reason treated as terminal with -fail-fast, can be repeated")
+	followLogs := flag.Bool("follow-logs", false, "Stream the restore job logs while the restore runs")
+	waitMode := flag.String("wait-mode", task.WaitModeWatch, "How to wait for the restore and upload: watch (falls back to poll when forbidden) or poll")
+	pollInterval := flag.Duration("poll-interval", task.DefaultPollInterval, "Interval between gets when polling the restore and upload")
+	logConcurrency := flag.Int("log-concurrency", task.DefaultLogConcurrency, "Number of pod logs streamed at the same time")
+	listOnly := flag.Bool("list-only", false, "Only list the files of the snapshot matching the restore filter, without restoring them")
+	dryRun := flag.Bool("dry-run", false, "Validate the configuration, snapshot and schedule and print the restore that would be submitted, without creating any resources")
+	previewArchive := flag.Bool("preview-archive", false, "Only list the files of the snapshot which would be archived, with their count and size, without restoring them")
+	resticImage := flag.String("restic-image", task.DefaultResticImage, "Image of the pods running restic for -list-only, -verify-file-count, -check-restore-size, -check-read-data or -unlock")
+	unlock := flag.Bool("unlock", false, "Remove stale locks with restic unlock when the restore fails because the repository is locked, use with caution")
+	resume := flag.Bool("resume", false, "Keep the restore PVC of a failed restore, and resume restoring into it on the next run or retry")
+	inspect := flag.Bool("inspect", false, "Start a pod with the restored files to inspect them instead of uploading an archive")
+	validateExec := flag.String("validate-exec", "", "Command run with sh in a pod with the restored files mounted, a non-zero exit fails the restore before it is uploaded")
+	validateImage := flag.String("validate-image", "", "Image of the -validate-exec pod, defaults to the task image")
+	inspectIdleTimeout := flag.Duration("inspect-idle-timeout", time.Hour, "How long the inspect pod runs without a shell open in it before it and the restored files are removed")
+	inspectDuration := flag.Duration("inspect-duration", 24*time.Hour, "How long the inspect pod runs at most before it and the restored files are removed, whether it is in use or not")
+	retries := flag.Int("retry", 0, "Number of times to retry the restore and upload on transient failures")
+	otlpEndpoint := flag.String("otlp-endpoint", otlpEndpointEnv, "OTLP/HTTP endpoint to export traces of the task phases to, tracing is disabled when empty")
+	debug := flag.Bool("debug", false, "Log debug information such as Kubernetes API request timings")
 
 	flag.Parse()
 
 	if len(flag.Args()) < 1 {
-		fmt.Println("Usage: restore-task [flags] [restore|upload]")
+		fmt.Println("Usage: restore-task [flags] [restore|upload|cleanup]")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
+	if err := task.CheckNamespace(*taskNamespace, *protectedNamespaces); err != nil {
+		log.Fatalf("Invalid namespace: %v", err)
+	}
+	if *restoreNamespace != "" {
+		if err := task.CheckNamespace(*restoreNamespace, *protectedNamespaces); err != nil {
+			log.Fatalf("Invalid restore namespace: %v", err)
+		}
+	}
+
+	// Further comma separated backup IDs are restored on top of the first one.
+	bid, mergeBackupIds, err := task.SplitBackupIds(*backupId)
+	if err != nil {
+		log.Fatalf("Invalid backup id: %v", err)
+	}
+	*backupId = bid
+	mergeBackupIds = append(mergeBackupIds, mergeBackupIdsArg...)
+
+	// A restore date selects the latest snapshot taken up to it.
+	var restoreBefore time.Time
+	if *restoreDate != "" {
+		if len(mergeBackupIds) > 0 {
+			log.Fatalf("A restore date can't be combined with multiple backup ids")
+		}
+		if *backupId != "" && *backupId != task.LatestSnapshot {
+			log.Fatalf("A restore date can't be combined with backup id %s", *backupId)
+		}
+		if *snapshotBefore != "" {
+			log.Fatalf("A restore date can't be combined with -snapshot-before")
+		}
+		before, err := task.ParseRestoreDate(*restoreDate)
+		if err != nil {
+			log.Fatalf("Invalid restore date: %v", err)
+		}
+		restoreBefore = before
+		*backupId = task.LatestSnapshot
+	}
+
+	if err := task.ValidateResourcePrefix(*resourcePrefix); err != nil {
+		log.Fatalf("Invalid resource prefix: %v", err)
+	}
+
 	// Generate k8s config from file, fall back to in-cluster config.
 	kConfig, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
 	if err != nil {
 		log.Fatalf("Failed to load kubernetes config: %v", err)
 	}
 
+	var apiMetrics *task.APIMetrics
+	if *debug {
+		apiMetrics = &task.APIMetrics{}
+		kConfig.Wrap(apiMetrics.Wrap)
+	}
+
 	t, err := task.NewRestoreTask(
 		*backupId,
 		*restoreFilter,
@@ -94,30 +290,424 @@ func Execute() {
 		*tokenHost,
 		*tokenPort,
 		*apiHost,
+		*resourcePrefix,
 	)
 	if err != nil {
 		log.Fatalf("Failed to load task config: %v", err)
 	}
+	if err := task.ValidateLogFormat(*logFormat); err != nil {
+		log.Fatalf("Invalid log format: %v", err)
+	}
+	t.SetupLogging(*logFormat)
+
+	// Abort on termination, in progress restores are rolled back by the cleanup.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	t.Ctx = ctx
+
+	switch *onEmpty {
+	case task.OnEmptyFail, task.OnEmptyWarn, task.OnEmptySkipUpload:
+		t.OnEmpty = *onEmpty
+	default:
+		log.Fatalf("Invalid on-empty behaviour %q, must be one of: fail, warn, skip-upload", *onEmpty)
+	}
+
+	if *lookupRetries < 1 {
+		log.Fatalf("Invalid lookup retries %d, must be at least 1", *lookupRetries)
+	}
+	t.LookupBackoff.Steps = *lookupRetries
+	if *cleanupRetries < 1 {
+		log.Fatalf("Invalid cleanup retries %d, must be at least 1", *cleanupRetries)
+	}
+	t.CleanupBackoff.Steps = *cleanupRetries
+	t.ReclaimRetainedPVs = *reclaimRetainedPVs
+	t.AllowHostMismatch = *allowHostMismatch
+
+	if err := task.ValidatePVCSize(*pvcSize); err != nil {
+		log.Fatalf("Invalid pvc size: %v", err)
+	}
+	t.PVCSize = *pvcSize
+	t.StorageClass = *storageClass
+	t.VolumeMode = corev1.PersistentVolumeMode(*volumeMode)
+	if err := task.ValidateVolumeMode(t.VolumeMode); err != nil {
+		log.Fatalf("Invalid volume mode: %v", err)
+	}
+
+	switch *outputFormat {
+	case task.OutputFormatArchive, task.OutputFormatDirectory, task.OutputFormatFiles:
+		t.OutputFormat = *outputFormat
+	default:
+		log.Fatalf("Invalid output format %q, must be one of: archive, directory, files", *outputFormat)
+	}
+	if t.OutputFormat == task.OutputFormatFiles {
+		if err := task.ValidateUploadFiles(*uploadFiles); err != nil {
+			log.Fatalf("Invalid upload files: %v", err)
+		}
+		if *maxUploadFiles < 1 || *maxUploadFiles > task.MaxUploadFilesLimit {
+			log.Fatalf("Invalid maximum upload files %d, must be between 1 and %d", *maxUploadFiles, task.MaxUploadFilesLimit)
+		}
+		switch *uploadAtomicity {
+		case task.UploadAtomicityBestEffort, task.UploadAtomicityAllOrNothing:
+		default:
+			log.Fatalf("Invalid upload atomicity %q, must be one of: best-effort, all-or-nothing", *uploadAtomicity)
+		}
+		t.UploadFiles = *uploadFiles
+		t.MaxUploadFiles = *maxUploadFiles
+		t.UploadAtomicity = *uploadAtomicity
+	}
+
+	t.IntegrityCheck = *integrityCheck
+	t.VerifyArchive = *verifyArchive
+	if *verifySample != "" {
+		files, percent, err := task.ParseVerifySample(*verifySample)
+		if err != nil {
+			log.Fatalf("Invalid verify sample: %v", err)
+		}
+		t.VerifySampleFiles = files
+		t.VerifySamplePercent = percent
+	}
+	if *integrityCheckPercent < 1 || *integrityCheckPercent > 100 {
+		log.Fatalf("Invalid integrity check percent %d, must be between 1 and 100", *integrityCheckPercent)
+	}
+	t.IntegrityCheckPercent = *integrityCheckPercent
+	if *checkReadData < 0 || *checkReadData > 100 {
+		log.Fatalf("Invalid check read data %d, must be between 0 and 100", *checkReadData)
+	}
+	t.FollowLogs = *followLogs
+	if *logConcurrency < 1 {
+		log.Fatalf("Invalid log concurrency %d, must be at least 1", *logConcurrency)
+	}
+	t.LogConcurrency = *logConcurrency
+
+	switch *waitMode {
+	case task.WaitModeWatch, task.WaitModePoll:
+		t.WaitMode = *waitMode
+	default:
+		log.Fatalf("Invalid wait mode %q, must be one of: watch, poll", *waitMode)
+	}
+	if *pollInterval <= 0 {
+		log.Fatalf("Invalid poll interval %s, must be positive", *pollInterval)
+	}
+	t.PollInterval = *pollInterval
+
+	if *restoreWorkers < 0 || *restoreWorkers > task.MaxRestoreWorkers {
+		log.Fatalf("Invalid restore workers %d, must be between 1 and %d, or 0 for the restic default", *restoreWorkers, task.MaxRestoreWorkers)
+	}
+	t.RestoreWorkers = *restoreWorkers
+	if *pvcTerminationTimeout < 0 {
+		log.Fatalf("Invalid pvc termination timeout %s, must not be negative", *pvcTerminationTimeout)
+	}
+	t.PVCTerminationTimeout = *pvcTerminationTimeout
+	if *backupWaitTimeout <= 0 {
+		log.Fatalf("Invalid backup wait timeout %s, must be positive", *backupWaitTimeout)
+	}
+	if *scaleDownTimeout <= 0 {
+		log.Fatalf("Invalid scale down timeout %s, must be positive", *scaleDownTimeout)
+	}
+	t.ScaleDownTimeout = *scaleDownTimeout
+	t.Force = *force
+
+	if *restoreBackoffLimit < -1 {
+		log.Fatalf("Invalid restore backoff limit %d, must be at least 0", *restoreBackoffLimit)
+	}
+	if *restoreBackoffLimit >= 0 {
+		limit := int32(*restoreBackoffLimit)
+		t.RestoreBackoffLimit = &limit
+	}
+	if len(failFastReasons) > 0 && !*failFast {
+		log.Fatalf("-fail-fast-reason only applies with -fail-fast")
+	}
+	if *failFast {
+		t.FailFastReasons = append(append([]string{}, task.DefaultFailFastReasons...), failFastReasons...)
+	}
+
+	t.RestoreMethods, err = task.ParseRestoreMethods(*restoreMethods)
+	if err != nil {
+		log.Fatalf("Invalid restore methods: %v", err)
+	}
+	t.S3Restore = &k8upv1.S3Spec{
+		Endpoint: *s3Endpoint,
+		Bucket:   *s3Bucket,
+	}
+	if *s3Secret != "" {
+		t.S3Restore.AccessKeyIDSecretRef = &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: *s3Secret},
+			Key:                  "AWS_ACCESS_KEY_ID",
+		}
+		t.S3Restore.SecretAccessKeySecretRef = &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: *s3Secret},
+			Key:                  "AWS_SECRET_ACCESS_KEY",
+		}
+	}
+
+	if *archiveMode != "" {
+		mode, err := strconv.ParseUint(*archiveMode, 8, 32)
+		if err != nil || mode > 0777 {
+			log.Fatalf("Invalid archive mode %q, must be an octal file mode", *archiveMode)
+		}
+		t.ArchiveMode = os.FileMode(mode)
+	}
+
+	if *archiveOwner != "" {
+		t.ArchiveUID, t.ArchiveGID, err = task.ParseArchiveOwner(*archiveOwner)
+		if err != nil {
+			log.Fatalf("Invalid archive owner: %v", err)
+		}
+	}
+	if *listFiles < 0 {
+		log.Fatalf("Invalid list files %d, must be 0 or more", *listFiles)
+	}
+	t.ListFiles = *listFiles
+	t.DiffTarget = *diffTarget
+	if *diffOnly && *diffSnapshot == "" {
+		log.Fatalf("-diff-only needs -diff-snapshot")
+	}
+	t.DiffSnapshot = *diffSnapshot
+	t.DiffOnly = *diffOnly
+	t.Args.Description = *description
+	t.Args.SnapshotId = snapshotIdArg
+	t.Args.MergeBackupIds = mergeBackupIds
+	if len(mergeBackupIds) > 0 {
+		switch {
+		case *inPlace != "":
+			log.Fatalf("Multiple backup ids can't be restored in place")
+		case *resume || *reuseRestore || *reuseRestoreFrom != "" || *keepRestoreOnFailure:
+			log.Fatalf("Restores of multiple backup ids can't be resumed, kept or reused")
+		case slices.Contains(t.RestoreMethods, task.RestoreMethodS3):
+			log.Fatalf("Multiple backup ids can't be restored with the s3 restore method")
+		case *verifyFileCount || *expectedFiles >= 0:
+			log.Fatalf("Restores of multiple backup ids can't be checked with -verify-file-count, only the files of one snapshot are counted")
+		case *checkRestoreSize:
+			log.Fatalf("Restores of multiple backup ids can't be checked with -check-restore-size, only the files of one snapshot are summed")
+		}
+	}
+	if *checkRestoreSize && *inPlace != "" {
+		log.Fatalf("In-place restores can't be checked with -check-restore-size, they restore into the existing PVC")
+	}
+	t.NoInfoFile = *noInfoFile
+	t.Reproducible = *reproducible
+	t.Strict = *strict
+	t.ExcludeHidden = *excludeHidden
+	if err := task.ValidateExcludePaths(excludePaths); err != nil {
+		log.Fatalf("Invalid exclude path: %v", err)
+	}
+	t.ExcludePaths = excludePaths
+	if t.IncludeExtensions, err = task.ParseExtensions(includeExtensions); err != nil {
+		log.Fatalf("Invalid included extension: %v", err)
+	}
+	if t.ExcludeExtensions, err = task.ParseExtensions(excludeExtensions); err != nil {
+		log.Fatalf("Invalid excluded extension: %v", err)
+	}
+	t.LogSections = *logSections
+
+	switch *sparseFiles {
+	case task.SparseFilesWarn, task.SparseFilesExclude, task.SparseFilesFail:
+		t.SparseFiles = *sparseFiles
+	default:
+		log.Fatalf("Invalid sparse files handling %q, must be one of: warn, exclude, fail", *sparseFiles)
+	}
+
+	switch *caseCollisions {
+	case task.CaseCollisionsWarn, task.CaseCollisionsFail, task.CaseCollisionsRename:
+		t.CaseCollisions = *caseCollisions
+	default:
+		log.Fatalf("Invalid case collisions handling %q, must be one of: warn, fail, rename", *caseCollisions)
+	}
+	t.Rsyncable = *rsyncable
+	blockSize, err := humanize.ParseBytes(*compressionBlockSize)
+	if err != nil {
+		log.Fatalf("Invalid compression block size: %v", err)
+	}
+	if err := task.ValidateCompression(*compressionLevel, *compressionThreads, int(blockSize), *rsyncable); err != nil {
+		log.Fatalf("Invalid compression settings: %v", err)
+	}
+	if err := task.ValidateArchiveFormat(*archiveFormat, *compressionThreads, *rsyncable); err != nil {
+		log.Fatalf("Invalid archive format: %v", err)
+	}
+	t.ArchiveFormat = *archiveFormat
+	t.CompressionLevel = *compressionLevel
+	t.CompressionThreads = *compressionThreads
+	t.CompressionBlockSize = int(blockSize)
+	if *fileCountTolerance < 0 || *fileCountTolerance > 100 {
+		log.Fatalf("Invalid file count tolerance %d, must be between 0 and 100", *fileCountTolerance)
+	}
+	t.ExpectedFiles = *expectedFiles
+	t.FileCountTolerance = *fileCountTolerance
+	if _, err := task.ParseRecipients(encryptRecipients); err != nil {
+		log.Fatalf("Invalid encryption: %v", err)
+	}
+	t.EncryptRecipients = encryptRecipients
+	for _, id := range additionalTaskIds {
+		if _, err := strconv.Atoi(id); err != nil {
+			log.Fatalf("Invalid additional task ID %q, must be numeric", id)
+		}
+	}
+	t.AdditionalTaskIds = additionalTaskIds
+	switch *symlinks {
+	case task.SymlinksPreserve, task.SymlinksFollow, task.SymlinksSkip:
+		t.Symlinks = *symlinks
+	default:
+		log.Fatalf("Invalid symlinks handling %q, must be one of: preserve, follow, skip", *symlinks)
+	}
+	if err := task.ValidateRepositoryPath(*repositoryPath); err != nil {
+		log.Fatalf("Invalid repository path: %v", err)
+	}
+	t.RepositoryPath = *repositoryPath
+	t.GlobalS3Endpoint = *globalS3Endpoint
+	t.GlobalS3Bucket = *globalS3Bucket
+	t.GlobalS3Secret = *globalS3Secret
+	if *snapshotBefore != "" {
+		if t.SnapshotBefore, err = time.Parse(time.RFC3339, *snapshotBefore); err != nil {
+			log.Fatalf("Invalid snapshot before time: %v", err)
+		}
+	}
+	if !restoreBefore.IsZero() {
+		t.SnapshotBefore = restoreBefore
+	}
+	if *snapshotAfter != "" {
+		if t.SnapshotAfter, err = time.Parse(time.RFC3339, *snapshotAfter); err != nil {
+			log.Fatalf("Invalid snapshot after time: %v", err)
+		}
+	}
+	if !t.SnapshotBefore.IsZero() && !t.SnapshotAfter.IsZero() && !t.SnapshotAfter.Before(t.SnapshotBefore) {
+		log.Fatalf("Invalid snapshot window, -snapshot-after must be before -snapshot-before")
+	}
+	if *backupId == task.LatestSnapshot && *repositoryPath != "" {
+		log.Fatalf("The latest snapshot can't be resolved with -repository-path, pass the full snapshot ID")
+	}
+	t.KeepArchiveOnFailure = *keepArchiveOnFailure
+	t.ArchivePVC = *archivePVC
+	if *uploadTimeout < 0 {
+		log.Fatalf("Invalid upload timeout %s, must not be negative", *uploadTimeout)
+	}
+	t.UploadTimeout = *uploadTimeout
+	if *restoreTimeout < 0 {
+		log.Fatalf("Invalid restore timeout %s, must not be negative", *restoreTimeout)
+	}
+	t.RestoreTimeout = *restoreTimeout
+	if *uploadPodTimeout < 0 {
+		log.Fatalf("Invalid upload pod timeout %s, must not be negative", *uploadPodTimeout)
+	}
+	t.UploadPodTimeout = *uploadPodTimeout
+	// The upload runs in the upload pod, so the wait for the pod would end a longer upload first.
+	if *uploadTimeout > 0 && *uploadPodTimeout > 0 && *uploadTimeout > *uploadPodTimeout {
+		log.Fatalf("Invalid upload timeout %s, must not exceed the upload pod timeout %s, raise -upload-pod-timeout too", *uploadTimeout, *uploadPodTimeout)
+	}
+	switch *onClosedTask {
+	case task.OnClosedTaskFail, task.OnClosedTaskKeepArchive:
+		t.OnClosedTask = *onClosedTask
+	default:
+		log.Fatalf("Invalid on-closed-task behaviour %q, must be one of: fail, keep-archive", *onClosedTask)
+	}
+
+	if *volume != "" {
+		volumes, err := task.ParseVolumeMap(volumeMap)
+		if err != nil {
+			log.Fatalf("Invalid volume map: %v", err)
+		}
+		t.Args.RestoreFilter, err = task.VolumeRestoreFilter(*volume, *restoreFilter, volumes)
+		if err != nil {
+			log.Fatalf("Invalid volume: %v", err)
+		}
+	}
+
+	t.ResticEnv, err = task.ParseResticEnv(resticEnv)
+	if err != nil {
+		log.Fatalf("Invalid restic env: %v", err)
+	}
+	if *resticCachePVC != "" {
+		for _, env := range t.ResticEnv {
+			if env.Name == "RESTIC_CACHE_DIR" {
+				log.Fatalf("Invalid restic env: RESTIC_CACHE_DIR is set by -restic-cache-pvc")
+			}
+		}
+	}
+	t.ResticCachePVC = *resticCachePVC
+	t.ResticOptions, err = task.ParseResticArgs(resticArgs)
+	if err != nil {
+		log.Fatalf("Invalid restic arg: %v", err)
+	}
 
 	subcommand := flag.Args()[0]
 
+	targetData := task.TargetData{Namespace: *taskNamespace, TaskID: *taskId, Snapshot: *backupId}
+	if *restoreTarget, err = task.RenderTarget(*restoreTarget, targetData); err != nil {
+		log.Fatalf("Invalid restore target: %v", err)
+	}
+	if *archiveTarget, err = task.RenderTarget(*archiveTarget, targetData); err != nil {
+		log.Fatalf("Invalid archive target: %v", err)
+	}
+	if t.PVCAnnotations, err = task.ParsePVCAnnotations(pvcAnnotations, targetData); err != nil {
+		log.Fatalf("Invalid pvc annotation: %v", err)
+	}
+	if err := task.CheckRequiredPVCAnnotations(t.PVCAnnotations, requiredPVCAnnotations); err != nil {
+		log.Fatalf("Invalid pvc annotations: %v", err)
+	}
+
+	// Restore and archive targets are mount paths in the upload pod, local runs of upload may use
+	// relative paths.
+	*restoreTarget, *archiveTarget, err = task.NormalizeTargets(*restoreTarget, *archiveTarget, subcommand != "upload")
+	if err != nil {
+		log.Fatalf("Invalid target paths: %v", err)
+	}
+
 	// This is running as a sub-pod of the main task to upload the restored files.
 	if subcommand == "upload" {
 		if *backupId == "" || *taskId == "" || *tokenHost == "" || *tokenPort == "" || *apiHost == "" {
 			log.Fatalf("Missing one of: backup id, task id, token host, token port, api host")
 		}
 
+		t.SSHKeyPath = *sshKey
+		if err := t.ValidateSSHKey(); err != nil {
+			log.Fatalf("Invalid SSH key: %v", err)
+		}
+
 		UploadPVCToTask(t, *restoreTarget, *archiveTarget)
 		return
 	}
 
+	// Removes resources left behind by a previous run of the task.
+	if subcommand == "cleanup" {
+		if *taskNamespace == "" || *taskId == "" {
+			log.Fatalf("Missing one of: namespace, task id")
+		}
+
+		if *restoreNamespace != "" {
+			if err := t.UseRestoreNamespace(*restoreNamespace, false); err != nil {
+				log.Fatalf("Failed to use restore namespace: %v", err)
+			}
+		}
+
+		if err := CleanupTask(t); err != nil {
+			log.Fatalf("Failed to clean up: %v", err)
+		}
+		return
+	}
+
 	if subcommand != "restore" {
 		log.Fatalf("Unknown subcommand %s", subcommand)
 	}
 
+	reporter, err := NewReporter(*output, *backupId)
+	if err != nil {
+		log.Fatalf("Invalid output: %v", err)
+	}
+	if *logSections {
+		reporter.LogSections()
+	}
+	if t.LogFormat == task.LogFormatJSON {
+		reporter.JSONLogs()
+	}
+	if *completionMarker != "" {
+		reporter.WriteCompletionMarker(*completionMarker, *taskId, *taskNamespace)
+	}
+	if apiMetrics != nil {
+		reporter.OnExit(apiMetrics.LogSummary)
+	}
+
 	// This is the main task that restores files and starts a sub-pod to upload it to Lagoon.
-	if *backupId == "" || *restoreFilter == "" || *taskNamespace == "" || *taskId == "" {
-		log.Fatalf("Missing one of: namespace, task id, snapshot id, or restore filter")
+	if *backupId == "" || (t.Args.RestoreFilter == "" && !*listOnly && !*previewArchive) || *taskNamespace == "" || *taskId == "" {
+		reporter.Fatalf("Missing one of: namespace, task id, snapshot id, or restore filter")
 	}
 
 	log.Println("==================")
@@ -126,33 +716,523 @@ func Execute() {
 	log.Println("==================")
 	fmt.Println()
 
-	restoreResult, err := RestoreToPVC(t)
+	// Report RBAC misconfiguration of the service account up front.
+	if *kubeconfig == "" {
+		missing, err := t.MissingPermissions(task.PermissionFeatures{
+			RestoreNamespace:       *restoreNamespace != "" && *restoreNamespace != t.Namespace,
+			CreateRestoreNamespace: *createRestoreNamespace,
+			InPlace:                *inPlace != "",
+			ScaleDown:              *inPlace != "" && *scaleDown,
+		})
+		if err != nil {
+			log.Printf("Warning: failed to check service account permissions: %v", err)
+		} else if len(missing) > 0 {
+			log.Printf("Warning: the service account is missing permissions in namespace %s:", t.Namespace)
+			for _, p := range missing {
+				log.Printf("  - %s", p)
+			}
+			fmt.Println()
+		}
+	}
+
+	shutdownTracing, err := task.SetupTracing(t.Ctx, *otlpEndpoint)
 	if err != nil {
-		log.Fatalf("Failed to restore backup: %v", err)
+		reporter.Fatalf("Failed to set up tracing: %v", err)
+	}
+	endTask := t.StartSpan("restore-files-task",
+		attribute.String("task.id", t.TaskId),
+		attribute.String("namespace", t.Namespace),
+		attribute.String("snapshot", t.Args.BackupId),
+		attribute.String("restore_filter", t.Args.RestoreFilter),
+	)
+	reporter.OnExit(func() {
+		endTask(nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Failed to export traces: %v", err)
+		}
+	})
+
+	t.Section("validate")
+	endValidate := t.StartSpan("validate")
+	if err := t.CheckNamespaceActive(t.SourceNamespace); err != nil {
+		endValidate(err)
+		reporter.Fatalf("Invalid namespace: %v", err)
+	}
+	t.PriorityClass = *priorityClass
+	if err := t.ValidatePriorityClass(); err != nil {
+		endValidate(err)
+		reporter.Fatalf("Invalid priority class: %v", err)
+	}
+	// Listing and in-place restores create no PVCs.
+	if !*listOnly && !*previewArchive && *inPlace == "" {
+		if err := t.ValidateStorageClass(); err != nil {
+			endValidate(err)
+			reporter.Fatalf("Invalid storage class: %v", err)
+		}
+	}
+	if err := t.ValidateResticCachePVC(); err != nil {
+		endValidate(err)
+		reporter.Fatalf("Invalid restic cache pvc: %v", err)
+	}
+
+	snapshotId, err := t.ResolveSnapshot(t.Args.BackupId)
+	if err != nil {
+		endValidate(err)
+		reporter.Fatalf("Failed to resolve snapshot: %v", err)
+	}
+	if snapshotId != t.Args.BackupId {
+		log.Printf("Resolved snapshot %s to %s", t.Args.BackupId, snapshotId)
+		t.Args.SnapshotId = snapshotId
+		reporter.Result.Snapshot = snapshotId
+	}
+	if t.DiffSnapshot != "" {
+		diffSnapshotId, err := t.ResolveSnapshot(t.DiffSnapshot)
+		if err != nil {
+			endValidate(err)
+			reporter.Fatalf("Failed to resolve diff snapshot: %v", err)
+		}
+		if diffSnapshotId == t.Args.Snapshot() {
+			err := fmt.Errorf("diff snapshot %s is the restored snapshot", t.DiffSnapshot)
+			endValidate(err)
+			reporter.Fatalf("Invalid diff snapshot: %v", err)
+		}
+		t.DiffSnapshot = diffSnapshotId
+	}
+	for i, id := range t.Args.MergeBackupIds {
+		mergeSnapshotId, err := t.ResolveSnapshot(id)
+		if err != nil {
+			endValidate(err)
+			reporter.Fatalf("Failed to resolve snapshot %s: %v", id, err)
+		}
+		t.Args.MergeBackupIds[i] = mergeSnapshotId
+	}
+	if err := t.CheckMergeSnapshots(); err != nil {
+		endValidate(err)
+		reporter.Fatalf("Invalid backup ids: %v", err)
+	}
+	// The host is read from the repository by a pod. restic restores snapshots by ID regardless of
+	// their host, only the resolved latest snapshot may be of another environment.
+	if t.Args.BackupId == task.LatestSnapshot || *checkSnapshotHost {
+		if *dryRun {
+			log.Println("Dry run, not checking the snapshot host")
+		} else if host, err := SnapshotHost(t, *resticImage); err != nil {
+			log.Printf("Warning: failed to check the snapshot host: %v", err)
+		} else if err := t.CheckSnapshotHost(host); err != nil {
+			endValidate(err)
+			reporter.Fatalf("Invalid snapshot host: %v", err)
+		}
+	}
+	t.SetSpanAttributes(attribute.String("snapshot", t.Args.Snapshot()))
+	endValidate(nil)
+
+	if *dryRun {
+		t.Section("plan")
+		fmt.Println()
+		if *restoreNamespace != "" && *restoreNamespace != t.SourceNamespace {
+			log.Printf("Would restore into namespace %s", *restoreNamespace)
+		}
+		if err := t.PlanRestore(); err != nil {
+			reporter.Fatalf("Failed to plan restore: %v", err)
+		}
+
+		log.Println("==================")
+		log.Println("Dry run completed, no resources were created")
+		log.Println("==================")
+
+		reporter.Success()
+		return
+	}
+
+	// Restores running alongside a backup contend for the repository lock.
+	if *waitForBackup {
+		if err := t.WaitForBackups(*backupWaitTimeout); err != nil {
+			reporter.Fatalf("Failed to wait for backups: %v", err)
+		}
+	} else if running, err := t.RunningBackups(); err != nil {
+		log.Printf("Warning: failed to check for running backups: %v", err)
+	} else if len(running) > 0 {
+		log.Printf("Warning: backups are running and may conflict with the restore: %v", running)
+	}
+
+	if *listOnly || *previewArchive {
+		t.Section("list")
+		if *previewArchive {
+			err = PreviewArchiveFiles(t, *resticImage)
+		} else {
+			err = ListSnapshotFiles(t, *resticImage)
+		}
+		if err != nil {
+			reporter.Fatalf("Failed to list snapshot files: %v", err)
+		}
+
+		fmt.Println()
+		log.Println("==================")
+		log.Println("Task completed")
+		log.Println("==================")
+
+		reporter.Success()
+		return
+	}
+
+	// Resources are created in the restore namespace from here on, the environment is only read.
+	if *restoreNamespace != "" && *restoreNamespace != t.SourceNamespace {
+		if *inPlace != "" || *diffDeployment != "" {
+			reporter.Fatalf("-in-place and -diff use the environment PVCs and can't be combined with -restore-namespace")
+		}
+
+		if err := t.UseRestoreNamespace(*restoreNamespace, *createRestoreNamespace); err != nil {
+			reporter.Fatalf("Failed to use restore namespace: %v", err)
+		}
+		reporter.OnExit(t.CleanupRestoreNamespace)
+
+		schedule, err := t.GetSchedule()
+		if err != nil {
+			reporter.Fatalf("Failed to get schedule: %v", err)
+		}
+		if err := t.CopySecrets(schedule); err != nil {
+			reporter.Fatalf("Failed to prepare restore namespace: %v", err)
+		}
+		log.Printf("Restoring into namespace %s", t.Namespace)
+		fmt.Println()
+	}
+
+	if *inPlace != "" {
+		if !*confirmInPlace {
+			reporter.Fatalf("In-place restore into %s overwrites live files, pass -confirm-in-place to continue", *inPlace)
+		}
+
+		if err := RestoreInPlace(t, *inPlace, *scaleDown); err != nil {
+			reporter.Fatalf("Failed to restore backup in place: %v", err)
+		}
+
+		fmt.Println()
+		log.Println("==================")
+		log.Println("Task completed")
+		log.Println("==================")
+
+		reporter.Success()
+		return
+	}
+
+	if *diffDeployment != "" && t.DiffSnapshot != "" {
+		reporter.Fatalf("-diff and -diff-snapshot can't be combined")
+	}
+	if t.DiffSnapshot != "" && t.DiffTarget == "" {
+		t.DiffTarget = "/diff-snapshot"
+	}
+
+	if *diffDeployment != "" {
+		livePVC, err := t.FindDeploymentPVC(*diffDeployment)
+		if err != nil {
+			reporter.Fatalf("Failed to find PVC to diff against: %v", err)
+		}
+		t.DiffPVC = livePVC.Name
+		if t.DiffTarget == "" {
+			t.DiffTarget = "/live"
+		}
+	}
+
+	if *inspect && *inspectDuration <= 0 {
+		reporter.Fatalf("Invalid inspect duration %s, must be positive", *inspectDuration)
+	}
+	if *inspect && (*inspectIdleTimeout < 10*time.Second || *inspectIdleTimeout > *inspectDuration) {
+		reporter.Fatalf("Invalid inspect idle timeout %s, must be at least 10s and at most the inspect duration %s", *inspectIdleTimeout, *inspectDuration)
+	}
+
+	t.Resume = *resume
+
+	if *uploadImage != "" {
+		if err := task.ValidateImage(*uploadImage); err != nil {
+			reporter.Fatalf("Invalid upload image: %v", err)
+		}
+	}
+
+	if *validateImage != "" {
+		if err := task.ValidateImage(*validateImage); err != nil {
+			reporter.Fatalf("Invalid validate image: %v", err)
+		}
+	}
+
+	// A restore kept by another task, eg the Lagoon task of a previous run, is found by its task ID.
+	reuseTaskId := *reuseRestoreFrom
+	if reuseTaskId == "" && *reuseRestore {
+		reuseTaskId = t.TaskId
+	}
+	opts := restoreOptions{
+		reuseTaskId:        reuseTaskId,
+		skipBootstrap:      *skipBootstrap,
+		inspect:            *inspect,
+		inspectIdleTimeout: *inspectIdleTimeout,
+		inspectDuration:    *inspectDuration,
+		taskImage:          *taskImage,
+		uploadImage:        *uploadImage,
+		validateExec:       *validateExec,
+		validateImage:      *validateImage,
+		resticImage:        *resticImage,
+		unlock:             *unlock,
+		verifyFileCount:    *verifyFileCount,
+		checkReadData:      *checkReadData,
+		checkRestoreSize:   *checkRestoreSize,
+		restoreTarget:      *restoreTarget,
+		archiveTarget:      *archiveTarget,
+	}
+	for attempt := 1; ; attempt++ {
+		err := restoreAndUpload(t, reporter, opts)
+		if err == nil {
+			break
+		}
+
+		// The restore of a failed upload is kept for the next attempt, and after the last attempt
+		// only with -keep-restore-on-failure.
+		var failedUpload *uploadFailedError
+		uploadFailed := errors.As(err, &failedUpload)
+		if attempt > *retries || !task.IsTransient(err) {
+			if uploadFailed {
+				keepFailedRestore(t, failedUpload.restore, *keepRestoreOnFailure)
+			}
+			reporter.Fatalf("Task failed: %v", err)
+		}
+
+		delay := time.Duration(attempt) * 10 * time.Second
+		log.Printf("Attempt %d failed with a transient error, retrying in %s: %v", attempt, delay, err)
+		fmt.Println()
+		select {
+		case <-t.Ctx.Done():
+			if uploadFailed {
+				keepFailedRestore(t, failedUpload.restore, *keepRestoreOnFailure)
+			}
+			reporter.Fatalf("Task aborted while waiting to retry: %v", err)
+		case <-time.After(delay):
+		}
+
+		// The restore of a failed upload is uploaded again. Other attempts are retried with fresh
+		// resources, the previous attempt cleaned up after itself. Resumed restores reuse the kept
+		// restore PVC.
+		if uploadFailed {
+			opts.reuseTaskId = strings.TrimPrefix(t.TaskKey, t.ResourcePrefix+"-")
+			continue
+		}
+		if !t.Resume {
+			t.TaskKey = fmt.Sprintf("%s-%s-r%d", t.ResourcePrefix, t.TaskId, attempt)
+		}
+		opts.reuseTaskId = ""
+	}
+
+	fmt.Println()
+	log.Println("==================")
+	log.Println("Task completed")
+	log.Println("==================")
+
+	reporter.Success()
+}
+
+// restoreOptions are the flags of a restore and upload attempt.
+type restoreOptions struct {
+	reuseTaskId        string
+	skipBootstrap      bool
+	inspect            bool
+	inspectIdleTimeout time.Duration
+	inspectDuration    time.Duration
+	taskImage          string
+	uploadImage        string
+	validateExec       string
+	validateImage      string
+	resticImage        string
+	unlock             bool
+	verifyFileCount    bool
+	checkReadData      int
+	checkRestoreSize   bool
+	restoreTarget      string
+	archiveTarget      string
+}
+
+// uploadFailedError is a failed upload of a completed restore, which is kept until the task decides
+// to retry the upload or to remove it.
+type uploadFailedError struct {
+	err     error
+	restore *RestoreToPVCResult
+}
+
+func (e *uploadFailedError) Error() string {
+	return fmt.Sprintf("failed to upload restore to task: %v", e.err)
+}
+
+func (e *uploadFailedError) Unwrap() error {
+	return e.err
+}
+
+// keepFailedRestore removes the restore of a failed upload, or keeps it with keep so a later task can
+// upload it with -reuse-restore-from.
+func keepFailedRestore(t *task.RestoreTask, restore *RestoreToPVCResult, keep bool) {
+	if !keep {
+		restore.Cleanup()
+		return
+	}
+	log.Printf("Keeping restore %s and pvc %s of the failed upload, run the task again with -reuse-restore-from %s to only upload them, or remove them with the cleanup subcommand", restore.Restore.Name, restore.PVC.Name, strings.TrimPrefix(t.TaskKey, t.ResourcePrefix+"-"))
+}
+
+// restoreAndUpload restores the backup and uploads the restored files, cleaning up all resources of
+// the attempt when it fails.
+func restoreAndUpload(t *task.RestoreTask, reporter *Reporter, opts restoreOptions) error {
+	var restoreResult *RestoreToPVCResult
+	var err error
+	t.Section("restore")
+	if opts.reuseTaskId != "" {
+		image, err := taskPodImage(t, opts.taskImage)
+		if err != nil {
+			return err
+		}
+		restoreResult, err = ReuseRestore(t, image, opts.restoreTarget, opts.reuseTaskId)
+		if err != nil {
+			return fmt.Errorf("failed to reuse restore: %w", err)
+		}
+		if restoreResult != nil {
+			log.Printf("Reusing completed restore %s in %s", restoreResult.Restore.Name, restoreResult.PVC.Name)
+		} else {
+			log.Println("No previous restore to reuse")
+		}
+	}
+
+	if restoreResult == nil && opts.checkRestoreSize {
+		if err := CheckSnapshotSize(t, opts.resticImage); err != nil {
+			return err
+		}
+	}
+
+	if restoreResult == nil {
+		restoreResult, err = RestoreToPVC(t)
+		if errors.Is(err, task.ErrRepositoryLocked) && opts.unlock {
+			if err := UnlockRepository(t, opts.resticImage); err != nil {
+				log.Printf("Failed to unlock repository: %v", err)
+			}
+		}
+		if err != nil {
+			if len(t.Args.MergeBackupIds) > 0 {
+				return fmt.Errorf("failed to restore snapshot %s: %w", t.Args.Snapshot(), err)
+			}
+			return fmt.Errorf("failed to restore backup: %w", err)
+		}
+	}
+
+	if len(t.Args.MergeBackupIds) > 0 {
+		fmt.Println()
+		if err := MergeSnapshots(t, restoreResult); err != nil {
+			restoreResult.Cleanup()
+			return err
+		}
 	}
 
 	log.Println("Restore completed")
 
-	if !*skipBootstrap {
+	// The diff snapshot is restored after the restore so both restores don't contend for the
+	// repository.
+	cleanupDiff := func() {}
+	if t.DiffSnapshot != "" && restoreResult.Method != task.RestoreMethodS3 {
+		fmt.Println()
+		diffResult, err := RestoreToPVC(t.DiffSnapshotTask())
+		if err != nil {
+			restoreResult.Cleanup()
+			return fmt.Errorf("failed to restore diff snapshot: %w", err)
+		}
+		t.DiffPVC = diffResult.PVC.Name
+		cleanupDiff = diffResult.Cleanup
+		cleanupRestore := restoreResult.Cleanup
+		restoreResult.Cleanup = func() {
+			diffResult.Cleanup()
+			cleanupRestore()
+		}
+		log.Println("Diff snapshot restore completed")
+	}
+
+	// S3 restores are written to the bucket by k8up, there are no files to upload.
+	if restoreResult.Method == task.RestoreMethodS3 {
+		bucket := t.S3Restore.Bucket
+		if bucket == "" {
+			bucket = "the global k8up restore bucket"
+		}
+		log.Printf("Restored files were written to %s, skipping upload", bucket)
+	} else if opts.inspect {
+		fmt.Println()
+		t.Section("inspect")
+		if err := InspectRestore(t, opts.taskImage, opts.restoreTarget, restoreResult.PVC, opts.inspectIdleTimeout, opts.inspectDuration); err != nil {
+			restoreResult.Cleanup()
+			return fmt.Errorf("failed to inspect restore: %w", err)
+		}
+	} else if !opts.skipBootstrap {
+		if opts.validateExec != "" {
+			image := opts.validateImage
+			if image == "" {
+				image, err = taskPodImage(t, opts.taskImage)
+				if err != nil {
+					restoreResult.Cleanup()
+					return err
+				}
+			}
+			if err := ValidateRestore(t, image, opts.restoreTarget, restoreResult.PVC, opts.validateExec); err != nil {
+				restoreResult.Cleanup()
+				return fmt.Errorf("restore validation failed: %w", err)
+			}
+			fmt.Println()
+		}
+
+		t.Section("upload")
 		log.Println("Starting upload")
 		fmt.Println()
 
-		bootstrapResult, err := BootstrapUploadPod(t, *taskImage, *restoreTarget, restoreResult.PVC, *archiveTarget)
+		if opts.checkReadData > 0 {
+			if err := CheckRepositoryData(t, opts.resticImage, opts.checkReadData); err != nil {
+				restoreResult.Cleanup()
+				return err
+			}
+		}
+
+		if opts.verifyFileCount {
+			expected, err := CountSnapshotFiles(t, opts.resticImage)
+			if err != nil {
+				restoreResult.Cleanup()
+				return fmt.Errorf("failed to count snapshot files: %w", err)
+			}
+			log.Printf("Snapshot contains %d files matching %s", expected, t.Args.RestoreFilter)
+			t.ExpectedFiles = expected
+		}
+
+		endUpload := t.StartSpan("upload")
+		bootstrapResult, err := BootstrapUploadPod(t, opts.taskImage, opts.uploadImage, opts.restoreTarget, restoreResult.PVC, opts.archiveTarget)
+		if upload := bootstrapResult.Upload; err == nil && upload != nil {
+			t.TracePhases(upload.Phases)
+			t.SetSpanAttributes(attribute.Int("files", upload.Files), attribute.Int64("bytes", upload.Bytes))
+		}
+		endUpload(err)
 		if err != nil {
-			restoreResult.Cleanup()
-			log.Fatalf("Failed to upload restore to task: %v", err)
+			// Keep the completed restore so the upload can be retried, restores of multiple snapshots
+			// can't be reused.
+			if len(t.Args.MergeBackupIds) > 0 {
+				restoreResult.Cleanup()
+				return fmt.Errorf("failed to upload restore to task: %w", err)
+			}
+			cleanupDiff()
+			restoreResult.Cleanup = func() { t.Cleanup(restoreResult.PVC, restoreResult.Restore, nil) }
+			return &uploadFailedError{err: err, restore: restoreResult}
 		}
 
 		fmt.Println()
 		log.Println("Upload completed")
 
+		reporter.Result.Uploaded = true
+		if upload := bootstrapResult.Upload; upload != nil {
+			reporter.Result.Uploaded = !upload.Skipped
+			reporter.Result.Archive = upload.Archive
+			reporter.Result.Files = upload.Files
+			reporter.Result.Bytes = upload.Bytes
+			reporter.Result.Checksum = upload.Checksum
+		}
+
 		bootstrapResult.Cleanup()
 	}
 
 	restoreResult.Cleanup()
 
-	fmt.Println()
-	log.Println("==================")
-	log.Println("Task completed")
-	log.Println("==================")
+	return nil
 }
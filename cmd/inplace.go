@@ -0,0 +1,75 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/amazeeio/lagoon-restore-files-task/internal/task"
+)
+
+// RestoreInPlace restores a backup directly into the PVC of a running deployment, optionally
+// scaling the deployment down for the duration of the restore.
+func RestoreInPlace(t *task.RestoreTask, deployment string, scaleDown bool) error {
+	pvc, err := t.FindDeploymentPVC(deployment)
+	if err != nil {
+		return fmt.Errorf("failed to find restore destination: %w", err)
+	}
+
+	log.Printf("Restoring %s from backup %s in place into pvc %s of deployment %s", t.Args.RestoreFilter, t.Args.BackupId, pvc.Name, deployment)
+	log.Printf("Restore task name: %s", t.TaskKey)
+	fmt.Println()
+
+	if scaleDown {
+		replicas, err := t.ScaleDeployment(deployment, 0)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			log.Printf("Scaling deployment %s back up to %d replicas", deployment, replicas)
+			if _, err := t.ScaleDeployment(deployment, replicas); err != nil {
+				log.Printf("Failed to scale deployment back up: %v", err)
+			}
+		}()
+
+		log.Printf("Scaled deployment %s down from %d replicas, waiting for pods to stop", deployment, replicas)
+		if err := t.WaitForScaleDown(deployment, t.ScaleDownTimeout); err != nil {
+			return err
+		}
+	}
+
+	restore, err := t.StartRestore(pvc)
+	if err != nil {
+		return fmt.Errorf("failed to start restore: %w", err)
+	}
+	log.Println("Starting restore")
+
+	// The PVC belongs to the deployment, only the restore is cleaned up.
+	defer t.Cleanup(nil, &restore, nil)
+
+	if err := t.WaitForRestore(restore); err != nil {
+		return fmt.Errorf("failed to wait for restore: %w", err)
+	}
+	fmt.Println()
+
+	if err := checkRestoreStatus(t, &restore); err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+
+	return nil
+}
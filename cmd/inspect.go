@@ -0,0 +1,134 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/amazeeio/lagoon-restore-files-task/internal/task"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// inspectIdleScript keeps the inspect pod running until no shell was open in it for the idle timeout.
+// The shell globs the processes itself, so between sleeps only the shell is left unless `kubectl exec`
+// sessions run in the container.
+const inspectIdleScript = `idle=0
+while [ "$idle" -lt "$IDLE_TIMEOUT" ]; do
+  sleep 10
+  set -- /proc/[0-9]*
+  if [ "$#" -gt 1 ]; then idle=0; else idle=$((idle + 10)); fi
+done`
+
+// InspectRestore starts a pod with the restore PVC mounted for operators to inspect the restored
+// files. It waits until the pod is removed, no shell was open in it for the idle timeout, or it has
+// run for the duration, and removes the pod.
+func InspectRestore(t *task.RestoreTask, taskImage string, restoreTarget string, restorePVC *corev1.PersistentVolumeClaim, idleTimeout time.Duration, duration time.Duration) error {
+	image, err := taskPodImage(t, taskImage)
+	if err != nil {
+		return err
+	}
+
+	schedule, err := t.GetSchedule()
+	if err != nil {
+		return fmt.Errorf("failed to get schedule: %w", err)
+	}
+
+	deadline := int64(duration.Seconds())
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("inspect-%s", t.TaskKey),
+			Annotations: task.BackupExcludedAnnotations(),
+		},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{
+					Name: "restore-target",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: restorePVC.Name,
+						},
+					},
+				},
+			},
+			Containers: []corev1.Container{
+				{
+					Name:       "inspect",
+					Image:      image,
+					Command:    []string{"sh", "-c", inspectIdleScript},
+					WorkingDir: restoreTarget,
+					Env: []corev1.EnvVar{
+						{
+							Name:  "IDLE_TIMEOUT",
+							Value: strconv.FormatInt(int64(idleTimeout.Seconds()), 10),
+						},
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "restore-target",
+							MountPath: restoreTarget,
+						},
+					},
+				},
+			},
+			// The pod is stopped after the duration even if a shell is still open or the task is gone.
+			ActiveDeadlineSeconds: &deadline,
+			RestartPolicy:         corev1.RestartPolicyNever,
+			ServiceAccountName:    t.PodServiceAccount(),
+			PriorityClassName:     t.PriorityClass,
+		},
+	}
+
+	// Run as same user as the backups and services.
+	if schedule.Spec.PodSecurityContext != nil {
+		pod.Spec.SecurityContext = schedule.Spec.PodSecurityContext
+	}
+
+	if err := t.Client.Create(t.Ctx, &pod); err != nil {
+		return fmt.Errorf("failed to create inspect pod: %w", err)
+	}
+	defer t.Cleanup(nil, nil, &pod)
+
+	log.Printf("Restored files are available in pod %s until no shell was open for %s, at most for %s, inspect them with:", pod.Name, idleTimeout, duration)
+	log.Printf("  kubectl -n %s exec -it %s -- sh", t.Namespace, pod.Name)
+	log.Printf("Delete the pod, or run the cleanup command of this task, when done")
+
+	err = wait.PollUntilContextCancel(t.Ctx, 10*time.Second, false, func(ctx context.Context) (bool, error) {
+		if err := t.Client.Get(ctx, client.ObjectKey{Name: pod.Name}, &pod); err != nil {
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+			log.Printf("Failed to get inspect pod: %v", err)
+			return false, nil
+		}
+		return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed, nil
+	})
+	if err != nil {
+		return fmt.Errorf("inspect aborted: %w", err)
+	}
+
+	log.Println("Inspection finished")
+
+	return nil
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/amazeeio/lagoon-restore-files-task/internal/task"
+	"github.com/dustin/go-humanize"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ListSnapshotFiles prints the files of the snapshot matching the restore filter without restoring
+// them.
+func ListSnapshotFiles(t *task.RestoreTask, image string) error {
+	log.Printf("Listing %s in backup %s", t.Args.RestoreFilter, t.Args.BackupId)
+	fmt.Println()
+
+	pod, err := t.StartListPod(image)
+	if err != nil {
+		return err
+	}
+	defer t.Cleanup(nil, nil, &pod)
+
+	if err := t.WaitForUpload(pod); err != nil {
+		return fmt.Errorf("failed to wait for listing: %w", err)
+	}
+
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: pod.Name}, &pod); err != nil {
+		return fmt.Errorf("failed to get list pod: %w", err)
+	}
+
+	log.Println("====== Snapshot files ======")
+	if err := t.PrintUploadLogs(pod); err != nil {
+		log.Printf("Failed to get logs: %v", err)
+	}
+
+	if pod.Status.Phase == corev1.PodFailed {
+		return fmt.Errorf("listing failed: %w", errors.New(pod.Status.Message))
+	}
+
+	return nil
+}
+
+// PreviewArchiveFiles prints the files of the snapshot which would end up in the archive, with their
+// count and total size, without restoring them.
+func PreviewArchiveFiles(t *task.RestoreTask, image string) error {
+	log.Printf("Previewing the archive of %s in backup %s", t.Args.RestoreFilter, t.Args.BackupId)
+	fmt.Println()
+
+	pod, err := t.StartPreviewPod(image)
+	if err != nil {
+		return err
+	}
+	defer t.Cleanup(nil, nil, &pod)
+
+	if err := t.WaitForUpload(pod); err != nil {
+		return fmt.Errorf("failed to wait for listing: %w", err)
+	}
+
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: pod.Name}, &pod); err != nil {
+		return fmt.Errorf("failed to get preview pod: %w", err)
+	}
+
+	if pod.Status.Phase == corev1.PodFailed {
+		if err := t.PrintUploadLogs(pod); err != nil {
+			log.Printf("Failed to get logs: %v", err)
+		}
+		return fmt.Errorf("listing failed: %w", errors.New(pod.Status.Message))
+	}
+
+	files, err := t.PreviewArchive(pod)
+	if err != nil {
+		return err
+	}
+
+	log.Println("====== Archive files ======")
+	var size uint64
+	for _, file := range files {
+		fmt.Printf("%s (%s)\n", file.Path, humanize.Bytes(file.Size))
+		size += file.Size
+	}
+	fmt.Println()
+	log.Printf("%d files, %s uncompressed, would be archived", len(files), humanize.Bytes(size))
+
+	return nil
+}
+
+// SnapshotHost reads the restic host of the snapshot from the repository, to check it with
+// task.CheckSnapshotHost.
+func SnapshotHost(t *task.RestoreTask, image string) (string, error) {
+	pod, err := t.StartHostPod(image)
+	if err != nil {
+		return "", err
+	}
+	defer t.Cleanup(nil, nil, &pod)
+
+	if err := t.WaitForUpload(pod); err != nil {
+		return "", fmt.Errorf("failed to wait for snapshots: %w", err)
+	}
+
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: pod.Name}, &pod); err != nil {
+		return "", fmt.Errorf("failed to get host pod: %w", err)
+	}
+
+	if pod.Status.Phase == corev1.PodFailed {
+		if err := t.PrintUploadLogs(pod); err != nil {
+			log.Printf("Failed to get logs: %v", err)
+		}
+		return "", fmt.Errorf("listing snapshots failed: %w", errors.New(pod.Status.Message))
+	}
+
+	return t.ReadSnapshotHost(pod)
+}
+
+// UnlockRepository removes stale locks from the repository, eg of a crashed backup.
+func UnlockRepository(t *task.RestoreTask, image string) error {
+	log.Println("Removing stale repository locks")
+
+	pod, err := t.StartUnlockPod(image)
+	if err != nil {
+		return err
+	}
+	defer t.Cleanup(nil, nil, &pod)
+
+	if err := t.WaitForUpload(pod); err != nil {
+		return fmt.Errorf("failed to wait for unlock: %w", err)
+	}
+
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: pod.Name}, &pod); err != nil {
+		return fmt.Errorf("failed to get unlock pod: %w", err)
+	}
+
+	if err := t.PrintUploadLogs(pod); err != nil {
+		log.Printf("Failed to get logs: %v", err)
+	}
+
+	if pod.Status.Phase == corev1.PodFailed {
+		return fmt.Errorf("unlock failed: %w", errors.New(pod.Status.Message))
+	}
+
+	return nil
+}
+
+// CheckRepositoryData reads and verifies a random percentage of the repository data with restic
+// check, failing when restic finds damaged data.
+func CheckRepositoryData(t *task.RestoreTask, image string, percent int) error {
+	log.Printf("Checking %d%% of the repository data", percent)
+
+	pod, err := t.StartCheckPod(image, percent)
+	if err != nil {
+		return err
+	}
+	defer t.Cleanup(nil, nil, &pod)
+
+	if err := t.WaitForUpload(pod); err != nil {
+		return fmt.Errorf("failed to wait for check: %w", err)
+	}
+
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: pod.Name}, &pod); err != nil {
+		return fmt.Errorf("failed to get check pod: %w", err)
+	}
+
+	if err := t.PrintUploadLogs(pod); err != nil {
+		log.Printf("Failed to get logs: %v", err)
+	}
+
+	if pod.Status.Phase == corev1.PodFailed {
+		return fmt.Errorf("repository check of %d%% of the data failed: %w", percent, errors.New(pod.Status.Message))
+	}
+
+	log.Printf("Checked %d%% of the repository data, no errors were found", percent)
+
+	return nil
+}
+
+// CheckSnapshotSize sums the sizes of the files of the snapshot matching the restore filter, to
+// check they fit into the restore PVC before restoring them.
+func CheckSnapshotSize(t *task.RestoreTask, image string) error {
+	pod, err := t.StartSizePod(image)
+	if err != nil {
+		return err
+	}
+	defer t.Cleanup(nil, nil, &pod)
+
+	if err := t.WaitForUpload(pod); err != nil {
+		return fmt.Errorf("failed to wait for size: %w", err)
+	}
+
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: pod.Name}, &pod); err != nil {
+		return fmt.Errorf("failed to get size pod: %w", err)
+	}
+
+	if pod.Status.Phase == corev1.PodFailed {
+		if err := t.PrintUploadLogs(pod); err != nil {
+			log.Printf("Failed to get logs: %v", err)
+		}
+		return fmt.Errorf("size failed: %w", errors.New(pod.Status.Message))
+	}
+
+	size, err := task.ReadRestoreSize(pod)
+	if err != nil {
+		return err
+	}
+	log.Printf("Snapshot files matching %s need %s", t.Args.RestoreFilter, humanize.IBytes(uint64(size)))
+
+	return t.CheckRestoreSize(size)
+}
+
+// CountSnapshotFiles counts the files of the snapshot matching the restore filter, to check the
+// restore is complete.
+func CountSnapshotFiles(t *task.RestoreTask, image string) (int, error) {
+	pod, err := t.StartCountPod(image)
+	if err != nil {
+		return 0, err
+	}
+	defer t.Cleanup(nil, nil, &pod)
+
+	if err := t.WaitForUpload(pod); err != nil {
+		return 0, fmt.Errorf("failed to wait for count: %w", err)
+	}
+
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: pod.Name}, &pod); err != nil {
+		return 0, fmt.Errorf("failed to get count pod: %w", err)
+	}
+
+	if pod.Status.Phase == corev1.PodFailed {
+		if err := t.PrintUploadLogs(pod); err != nil {
+			log.Printf("Failed to get logs: %v", err)
+		}
+		return 0, fmt.Errorf("count failed: %w", errors.New(pod.Status.Message))
+	}
+
+	return task.ReadFileCount(pod)
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TaskResult is the machine readable summary of a task run.
+type TaskResult struct {
+	Outcome  string `json:"outcome"`
+	Snapshot string `json:"snapshot"`
+	Files    int    `json:"files"`
+	Bytes    int64  `json:"bytes"`
+	Archive  string `json:"archive,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+	Uploaded bool   `json:"uploaded"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Reporter prints the outcome of the task in the configured output format.
+type Reporter struct {
+	json     bool
+	sections bool
+	jsonLogs bool
+	out      io.Writer
+	onExit   []func()
+	marker   *completionMarker
+	Result   TaskResult
+}
+
+// completionMarker is written when the task finishes, for orchestrators to detect completion.
+type completionMarker struct {
+	TaskResult
+	TaskID     string    `json:"taskId"`
+	Namespace  string    `json:"namespace"`
+	FinishedAt time.Time `json:"finishedAt"`
+	path       string
+}
+
+// NewReporter creates a reporter for the given output format. In json mode all human readable
+// output is moved to stderr so stdout only carries the final result. The log package, and slog
+// with JSON logs, already write to stderr, their output is left as set up by SetupLogging.
+func NewReporter(format string, snapshot string) (*Reporter, error) {
+	r := &Reporter{
+		out:    os.Stdout,
+		Result: TaskResult{Snapshot: snapshot},
+	}
+
+	switch format {
+	case "text":
+	case "json":
+		r.json = true
+		os.Stdout = os.Stderr
+	default:
+		return nil, fmt.Errorf("unknown output format %q, must be one of: text, json", format)
+	}
+
+	return r, nil
+}
+
+// OnExit registers a function to run before the result is reported.
+func (r *Reporter) OnExit(f func()) {
+	r.onExit = append(r.onExit, f)
+}
+
+// LogSections prints the result as a `::summary::` line after a `::section::summary` marker when
+// the task finishes, for the Lagoon UI to show the outcome of the task log.
+func (r *Reporter) LogSections() {
+	r.sections = true
+}
+
+// JSONLogs logs the failure of the task at error level with JSON logs.
+func (r *Reporter) JSONLogs() {
+	r.jsonLogs = true
+}
+
+// WriteCompletionMarker writes the result with the task ID and namespace as JSON to path when the
+// task finishes, successful or not.
+func (r *Reporter) WriteCompletionMarker(path string, taskId string, namespace string) {
+	r.marker = &completionMarker{TaskID: taskId, Namespace: namespace, path: path}
+}
+
+// Fatalf reports a failed task and exits.
+func (r *Reporter) Fatalf(format string, v ...any) {
+	r.runOnExit()
+	r.Result.Outcome = "failed"
+	r.Result.Error = fmt.Sprintf(format, v...)
+	r.writeMarker()
+	switch {
+	case r.jsonLogs:
+		slog.Error(r.Result.Error)
+	case !r.json && !r.sections:
+		log.Fatalf(format, v...)
+	default:
+		log.Printf(format, v...)
+	}
+
+	r.print()
+	os.Exit(1)
+}
+
+// Success reports a successful task.
+func (r *Reporter) Success() {
+	r.runOnExit()
+	r.Result.Outcome = "succeeded"
+	r.writeMarker()
+	if r.json || r.sections {
+		r.print()
+	}
+}
+
+func (r *Reporter) runOnExit() {
+	for _, f := range r.onExit {
+		f()
+	}
+}
+
+func (r *Reporter) print() {
+	if r.sections {
+		data, err := json.Marshal(r.Result)
+		if err != nil {
+			log.Printf("Failed to print summary: %v", err)
+		} else {
+			fmt.Println("::section::summary")
+			fmt.Printf("::summary::%s\n", data)
+		}
+	}
+
+	if !r.json {
+		return
+	}
+	if err := json.NewEncoder(r.out).Encode(r.Result); err != nil {
+		log.Printf("Failed to print result: %v", err)
+	}
+}
+
+// writeMarker writes the completion marker, if enabled. It is written to a temporary file first so
+// orchestrators polling for it never read a partial marker.
+func (r *Reporter) writeMarker() {
+	if r.marker == nil {
+		return
+	}
+
+	r.marker.TaskResult = r.Result
+	r.marker.FinishedAt = time.Now().UTC()
+	data, err := json.Marshal(r.marker)
+	if err != nil {
+		log.Printf("Failed to marshal completion marker: %v", err)
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.marker.path), ".completion-*.json")
+	if err != nil {
+		log.Printf("Failed to write completion marker: %v", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), r.marker.path)
+	}
+	if err != nil {
+		log.Printf("Failed to write completion marker: %v", err)
+	}
+}
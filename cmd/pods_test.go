@@ -0,0 +1,131 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/amazeeio/lagoon-restore-files-task/internal/task"
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// errPodRejected fails pod creation, so the pod builders return before waiting for the pod.
+var errPodRejected = errors.New("pod rejected")
+
+// newPodRecordingTask returns a task whose fake client records and rejects every pod created.
+func newPodRecordingTask(t *testing.T) (*task.RestoreTask, *[]corev1.Pod) {
+	t.Helper()
+
+	clientScheme := k8runtime.NewScheme()
+	if err := scheme.AddToScheme(clientScheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := k8upv1.AddToScheme(clientScheme); err != nil {
+		t.Fatal(err)
+	}
+
+	var pods []corev1.Pod
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(clientScheme).
+		WithRESTMapper(testrestmapper.TestOnlyStaticRESTMapper(clientScheme)).
+		WithObjects(&k8upv1.Schedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "k8up-lagoon-backup-schedule", Namespace: "project-main"},
+		}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if pod, ok := obj.(*corev1.Pod); ok {
+					pods = append(pods, *pod)
+					return errPodRejected
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	namespaceClient := client.NewNamespacedClient(fakeClient, "project-main")
+
+	return &task.RestoreTask{
+		Args:           task.TaskArgs{BackupId: "1a2b3c4d", RestoreFilter: "/nginx"},
+		Ctx:            context.Background(),
+		Client:         namespaceClient,
+		ClusterClient:  fakeClient,
+		SourceClient:   namespaceClient,
+		Namespace:      "project-main",
+		TaskId:         "127",
+		ResourcePrefix: task.DefaultResourcePrefix,
+		TaskKey:        task.DefaultResourcePrefix + "-127",
+		StorageClass:   task.DefaultStorageClass,
+		PVCSize:        task.DefaultPVCSize,
+		CleanupBackoff: task.DefaultCleanupBackoff,
+		ArchiveUID:     -1,
+		ArchiveGID:     -1,
+	}, &pods
+}
+
+func TestTaskPodsAreExcludedFromBackups(t *testing.T) {
+	restorePVC := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "restore-target-rft-127"}}
+
+	tests := []struct {
+		name  string
+		start func(t *task.RestoreTask) error
+	}{
+		{
+			name: "upload",
+			start: func(t *task.RestoreTask) error {
+				_, err := BootstrapUploadPod(t, "", "uselagoon/restore-files-task", "/restore", restorePVC, "/archive")
+				return err
+			},
+		},
+		{
+			name: "validate",
+			start: func(t *task.RestoreTask) error {
+				return ValidateRestore(t, "busybox", "/restore", restorePVC, "true")
+			},
+		},
+		{
+			name: "inspect",
+			start: func(t *task.RestoreTask) error {
+				return InspectRestore(t, "uselagoon/restore-files-task", "/restore", restorePVC, time.Hour, 24*time.Hour)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restoreTask, pods := newPodRecordingTask(t)
+			if err := tt.start(restoreTask); !errors.Is(err, errPodRejected) {
+				t.Fatalf("error = %v, want %v", err, errPodRejected)
+			}
+			if len(*pods) != 1 {
+				t.Fatalf("created %d pods, want 1", len(*pods))
+			}
+			if got := (*pods)[0].Annotations["k8up.io/backup"]; got != "false" {
+				t.Errorf("k8up.io/backup annotation = %q, want %q", got, "false")
+			}
+		})
+	}
+}
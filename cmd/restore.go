@@ -23,57 +23,97 @@ import (
 
 	"github.com/amazeeio/lagoon-restore-files-task/internal/task"
 	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	"go.opentelemetry.io/otel/attribute"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type RestoreToPVCResult struct {
+	// Method is the restore method used, the PVC is nil for S3 restores.
+	Method  string
 	PVC     *corev1.PersistentVolumeClaim
 	Restore *k8upv1.Restore
 	Cleanup func()
 }
 
-// RestoreToPVC creates a PVC and restores a backup to it.
+// errRestoreDestination marks failures to set up the restore destination, which fall back to the
+// next restore method.
+var errRestoreDestination = errors.New("failed to create restore destination")
+
+// RestoreToPVC restores a backup with the first restore method whose destination can be created.
 func RestoreToPVC(t *task.RestoreTask) (*RestoreToPVCResult, error) {
 	log.Printf("Restoring %s from backup %s", t.Args.RestoreFilter, t.Args.BackupId)
 
 	log.Printf("Restore task name: %s", t.TaskKey)
 	fmt.Println()
 
-	pvc, err := t.CreateRestorePVC(fmt.Sprintf("restore-target-%s", t.TaskKey), "1Gi")
-	if err != nil {
-		log.Fatalf("Failed to create restore destination: %v", err)
+	var errs []error
+	for i, method := range t.RestoreMethods {
+		result, err := restoreWithMethod(t, method)
+		if err == nil {
+			return result, nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s restore: %w", method, err))
+		if !errors.Is(err, errRestoreDestination) || i == len(t.RestoreMethods)-1 {
+			break
+		}
+		log.Printf("Failed %s restore, falling back to %s restore: %v", method, t.RestoreMethods[i+1], err)
 	}
 
-	restore, err := t.StartRestore(pvc)
-	if err != nil {
-		t.Cleanup(&pvc, nil, nil)
-		log.Fatalf("Failed to start restore: %v", err)
-	} else {
-		log.Println("Starting restore")
+	return &RestoreToPVCResult{}, errors.Join(errs...)
+}
+
+// restoreWithMethod creates the restore destination of the method and restores a backup to it.
+func restoreWithMethod(t *task.RestoreTask, method string) (*RestoreToPVCResult, error) {
+	var pvc *corev1.PersistentVolumeClaim
+	var restore k8upv1.Restore
+	var err error
+
+	switch method {
+	case task.RestoreMethodS3:
+		err = t.Trace("start-restore", func() error {
+			restore, err = t.StartS3Restore()
+			return err
+		})
+		if err != nil {
+			return &RestoreToPVCResult{}, fmt.Errorf("failed to start restore: %w", err)
+		}
+	default:
+		var newPVC corev1.PersistentVolumeClaim
+		err = t.Trace("create-pvc", func() error {
+			newPVC, err = restorePVC(t)
+			return err
+		})
+		if err != nil {
+			return &RestoreToPVCResult{}, err
+		}
+		pvc = &newPVC
+
+		err = t.Trace("start-restore", func() error {
+			restore, err = t.StartRestore(newPVC)
+			return err
+		})
+		if err != nil {
+			t.Cleanup(failedRestorePVC(t, pvc), nil, nil)
+			return &RestoreToPVCResult{}, fmt.Errorf("failed to start restore: %w", err)
+		}
 	}
+	log.Println("Starting restore")
 
+	endWait := t.StartSpan("wait-restore", attribute.String("restore", restore.Name))
 	err = t.WaitForRestore(restore)
 	if err != nil {
-		t.Cleanup(&pvc, &restore, nil)
-		log.Fatalf("Failed to wait for restore: %v", err)
+		endWait(err)
+		t.Cleanup(failedRestorePVC(t, pvc), &restore, nil)
+		return &RestoreToPVCResult{}, fmt.Errorf("failed to wait for restore: %w", err)
 	}
 	fmt.Println()
 
 	// Determine if the restore was a succcess.
-	var restoreFailed error
-	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: restore.Name}, &restore); err != nil {
-		restoreFailed = fmt.Errorf("failed to get restore: %w", err)
-	} else {
-		restoreCompleted := meta.FindStatusCondition(restore.Status.Conditions, "Completed")
-
-		if restoreCompleted == nil { // Triggered with condition Ready: CreationFailed.
-			restoreFailed = fmt.Errorf("restore status: %+v", restore.Status)
-		} else if restoreCompleted.Reason == "Failed" {
-			restoreFailed = errors.New(restoreCompleted.Message)
-		}
-	}
+	restoreFailed := checkRestoreStatus(t, &restore)
+	endWait(restoreFailed)
 
 	if restoreFailed != nil {
 		// // Manually created restores don't honor the FailedJobsHistoryLimit setting.
@@ -84,7 +124,12 @@ func RestoreToPVC(t *task.RestoreTask) (*RestoreToPVCResult, error) {
 		// 	log.Printf("Failed to get logs: %v", err)
 		// }
 
-		t.Cleanup(&pvc, &restore, nil)
+		// The restore pods are removed with the restore, check them for lock errors first.
+		if lockErr := t.CheckRepositoryLock(restore); lockErr != nil {
+			restoreFailed = lockErr
+		}
+
+		t.Cleanup(failedRestorePVC(t, pvc), &restore, nil)
 
 		return &RestoreToPVCResult{}, fmt.Errorf("restore failed: %w", restoreFailed)
 	} else {
@@ -95,9 +140,126 @@ func RestoreToPVC(t *task.RestoreTask) (*RestoreToPVCResult, error) {
 		// }
 
 		return &RestoreToPVCResult{
-			PVC:     &pvc,
+			Method:  method,
+			PVC:     pvc,
 			Restore: &restore,
-			Cleanup: func() { t.Cleanup(&pvc, &restore, nil) },
+			Cleanup: func() { t.Cleanup(pvc, &restore, nil) },
 		}, nil
 	}
 }
+
+// MergeSnapshots restores the merge snapshots one after another into the restore PVC, so files of
+// later snapshots overwrite files of earlier ones. Their restores are removed with the restore, it
+// fails naming the snapshot whose restore failed.
+func MergeSnapshots(t *task.RestoreTask, result *RestoreToPVCResult) error {
+	for i, snapshot := range t.Args.MergeBackupIds {
+		mt := t.MergeSnapshotTask(i)
+		log.Printf("Merging snapshot %s into %s", snapshot, result.PVC.Name)
+
+		var restore k8upv1.Restore
+		err := t.Trace("start-restore", func() error {
+			var err error
+			restore, err = mt.StartRestore(*result.PVC)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start restore of snapshot %s: %w", snapshot, err)
+		}
+		cleanupRestore := result.Cleanup
+		result.Cleanup = func() {
+			mt.Cleanup(nil, &restore, nil)
+			cleanupRestore()
+		}
+
+		endWait := t.StartSpan("wait-restore", attribute.String("restore", restore.Name))
+		if err := mt.WaitForRestore(restore); err != nil {
+			endWait(err)
+			return fmt.Errorf("failed to wait for restore of snapshot %s: %w", snapshot, err)
+		}
+		fmt.Println()
+
+		restoreFailed := checkRestoreStatus(mt, &restore)
+		endWait(restoreFailed)
+		if restoreFailed != nil {
+			if lockErr := mt.CheckRepositoryLock(restore); lockErr != nil {
+				restoreFailed = lockErr
+			}
+			return fmt.Errorf("restore of snapshot %s failed: %w", snapshot, restoreFailed)
+		}
+	}
+
+	return nil
+}
+
+// restorePVC creates the restore PVC, or gets the PVC of a previous failed restore to resume.
+func restorePVC(t *task.RestoreTask) (corev1.PersistentVolumeClaim, error) {
+	name := fmt.Sprintf("restore-target-%s", t.TaskKey)
+
+	if t.Resume {
+		pvc, found, err := t.ResumableRestorePVC(name)
+		if err != nil {
+			return pvc, err
+		}
+		if found {
+			log.Printf("Resuming restore into pvc %s", pvc.Name)
+			if err := t.RemoveStaleRestore(); err != nil {
+				return pvc, err
+			}
+			return pvc, nil
+		}
+	}
+
+	pvc, err := t.CreateRestorePVC(name, t.PVCSize)
+	if err != nil {
+		return pvc, fmt.Errorf("%w: %w", errRestoreDestination, err)
+	}
+	return pvc, nil
+}
+
+// failedRestorePVC returns the restore PVC to clean up after a failed restore, none when it is kept
+// to resume the restore.
+func failedRestorePVC(t *task.RestoreTask, pvc *corev1.PersistentVolumeClaim) *corev1.PersistentVolumeClaim {
+	if t.Resume && pvc != nil {
+		log.Printf("Keeping pvc %s to resume the restore, run the task again with -resume", pvc.Name)
+		return nil
+	}
+	return pvc
+}
+
+// ReuseRestore finds a completed restore kept after its upload failed by the task with the task ID,
+// to skip straight to the upload. It returns nil if there is no previous restore. The restore PVC
+// is checked for restored files with a pod of the image.
+func ReuseRestore(t *task.RestoreTask, image string, restoreTarget string, taskId string) (*RestoreToPVCResult, error) {
+	pvc, restore, err := t.ReusableRestore(taskId)
+	if err != nil || pvc == nil {
+		return nil, err
+	}
+
+	// The PVC may have been emptied or replaced since the restore completed.
+	if err := ValidateRestore(t, image, restoreTarget, pvc, `ls -A | grep -q .`); err != nil {
+		return nil, fmt.Errorf("previous restore destination %s has no restored files: %w", pvc.Name, err)
+	}
+
+	return &RestoreToPVCResult{
+		Method:  task.RestoreMethodFolder,
+		PVC:     pvc,
+		Restore: restore,
+		Cleanup: func() { t.Cleanup(pvc, restore, nil) },
+	}, nil
+}
+
+// checkRestoreStatus refreshes the restore and returns an error if it did not complete successfully.
+func checkRestoreStatus(t *task.RestoreTask, restore *k8upv1.Restore) error {
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: restore.Name}, restore); err != nil {
+		return fmt.Errorf("failed to get restore: %w", err)
+	}
+
+	restoreCompleted := meta.FindStatusCondition(restore.Status.Conditions, "Completed")
+	if restoreCompleted == nil { // Triggered with condition Ready: CreationFailed.
+		return fmt.Errorf("restore status: %+v", restore.Status)
+	} else if restoreCompleted.Reason == "Failed" {
+		return errors.New(restoreCompleted.Message)
+	}
+
+	return nil
+}
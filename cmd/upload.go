@@ -24,10 +24,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/amazeeio/lagoon-restore-files-task/internal/task"
 	"github.com/dustin/go-humanize"
-	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -35,51 +37,471 @@ import (
 
 // UploadPVCToTask compresses the restored files in the PVC and uploads it to the Lagoon task.
 func UploadPVCToTask(t *task.RestoreTask, restoreTarget string, archiveTarget string) {
+	var diff task.TreeDiff
+	if t.DiffTarget != "" {
+		log.Printf("Comparing restored files with %s", t.DiffTarget)
+		var err error
+		diff, err = task.DiffTrees(restoreTarget, t.DiffTarget)
+		if err != nil {
+			log.Fatalf("Failed to diff restored files: %v", err)
+		}
+		task.LogDiff(diff)
+	}
+
+	if t.IntegrityCheck {
+		log.Println("Verifying restored files")
+		verified, listed, err := task.CheckIntegrity(restoreTarget, t.IntegrityCheckPercent)
+		if err != nil {
+			log.Fatalf("Failed integrity check: %v", err)
+		}
+		log.Printf("Verified %d of %d restored files listed in checksum manifests (%d%% sample)", verified, listed, t.IntegrityCheckPercent)
+	}
+
+	if t.VerifySampleFiles > 0 || t.VerifySamplePercent > 0 {
+		log.Println("Reading back a sample of restored files")
+		sampled, total, empty, err := task.VerifySample(restoreTarget, t.VerifySampleFiles, t.VerifySamplePercent)
+		if err != nil {
+			log.Fatalf("Failed sample verification: %v", err)
+		}
+		if len(empty) > 0 {
+			log.Printf("Warning: %d sampled files are empty: %s", len(empty), strings.Join(empty, ", "))
+		}
+		log.Printf("Read back %d of %d restored files", sampled, total)
+	}
+
+	if t.ExpectedFiles >= 0 {
+		if err := t.CheckFileCount(restoreTarget); err != nil {
+			log.Fatalf("Incomplete restore: %v", err)
+		}
+	}
+
+	if t.ListFiles > 0 {
+		log.Println("Restored files:")
+		if err := task.LogRestoredFiles(restoreTarget, t.ListFiles); err != nil {
+			log.Printf("Failed to list restored files: %v", err)
+		}
+	}
+
+	// The archive target of a shared archive PVC holds the output of the previous run.
+	if t.ArchivePVC != "" {
+		if err := task.ClearArchiveTarget(archiveTarget); err != nil {
+			log.Fatalf("Failed to clear archive target: %v", err)
+		}
+	}
+
+	if t.DiffSnapshot != "" {
+		uploadDiffReport(t, diff, restoreTarget, archiveTarget)
+		if t.DiffOnly {
+			os.Exit(0)
+		}
+	}
+
+	if t.OutputFormat == task.OutputFormatDirectory {
+		log.Println("Copying restored files")
+		dir, fileCount, err := t.CopyRestore(restoreTarget, archiveTarget)
+		if err != nil {
+			log.Fatalf("Failed to copy restored files: %v", err)
+		}
+		log.Printf("Copied %d files to %s, skipping upload", fileCount, dir)
+
+		err = task.WriteUploadResult(task.UploadResult{
+			Snapshot: t.Args.Snapshot(),
+			Archive:  dir,
+			Files:    fileCount,
+			Skipped:  true,
+		})
+		if err != nil {
+			log.Printf("Failed to write upload result: %v", err)
+		}
+		os.Exit(0)
+	}
+
+	if t.OutputFormat == task.OutputFormatFiles {
+		uploadRestoredFiles(t, restoreTarget, archiveTarget)
+		os.Exit(0)
+	}
+
+	t.Section("archive")
 	log.Println("Archiving restored files")
 
-	archive, err := t.ArchiveRestore(restoreTarget, archiveTarget)
+	var phases []task.Phase
+	var archive *os.File
+	var fileCount int
+	err := task.TimePhase(&phases, "archive", func() error {
+		var err error
+		archive, fileCount, err = t.ArchiveRestore(restoreTarget, archiveTarget)
+		return err
+	})
+	if errors.Is(err, task.ErrEmptyRestore) && t.OnEmpty == task.OnEmptySkipUpload {
+		log.Printf("Skipping upload: %v", err)
+		if err := task.WriteUploadResult(task.UploadResult{Snapshot: t.Args.Snapshot(), Skipped: true}); err != nil {
+			log.Printf("Failed to write upload result: %v", err)
+		}
+		os.Exit(0)
+	}
 	if err != nil {
 		// Cleanup is handled by parent task process.
 		log.Fatalf("Failed to archive restored files: %v", err)
 	}
 
+	if t.VerifyArchive {
+		var entries int
+		err = task.TimePhase(&phases, "verify", func() error {
+			var err error
+			entries, err = task.VerifyArchive(archive.Name(), t.ArchiveFormat)
+			return err
+		})
+		if err != nil {
+			log.Fatalf("Archive %s is corrupt: %v", archive.Name(), err)
+		}
+		log.Printf("Verified archive %s with %d entries", archive.Name(), entries)
+	}
+
+	if len(t.EncryptRecipients) > 0 {
+		err = task.TimePhase(&phases, "encrypt", func() error {
+			var err error
+			archive, err = t.EncryptArchive(archive)
+			return err
+		})
+		if err != nil {
+			log.Fatalf("Failed to encrypt archive: %v", err)
+		}
+		log.Printf("Encrypted archive with age for recipients: %s", strings.Join(t.EncryptRecipients, ", "))
+	}
+
 	archiveInfo, err := os.Stat(archive.Name())
 	if err != nil {
 		log.Fatalf("Failed to read archive: %v", err)
 	}
 
-	log.Printf("Uploading %s (%s) to Lagoon task %s", archive.Name(), humanize.Bytes(uint64(archiveInfo.Size())), t.TaskId)
+	checksum, err := task.ChecksumFile(archive.Name())
+	if err != nil {
+		log.Fatalf("Failed to checksum archive: %v", err)
+	}
 
-	err = t.UploadArchiveToLagoon(archive)
+	t.Section("upload")
+	log.Printf("Uploading %s (%s, %d files, sha256 %s) from snapshot %s to Lagoon task %s", archive.Name(), humanize.Bytes(uint64(archiveInfo.Size())), fileCount, checksum, t.Args.Snapshot(), t.TaskId)
+
+	var uploadedName string
+	err = task.TimePhase(&phases, "upload", func() error {
+		var err error
+		uploadedName, err = t.UploadArchiveToLagoon(archive)
+		return err
+	})
+	if errors.Is(err, task.ErrTaskClosed) {
+		// Report the closed task so the parent task can keep the archive.
+		err := task.WriteUploadResult(task.UploadResult{
+			Snapshot:   t.Args.Snapshot(),
+			Archive:    archive.Name(),
+			Files:      fileCount,
+			Bytes:      archiveInfo.Size(),
+			Checksum:   checksum,
+			Phases:     phases,
+			TaskClosed: true,
+		})
+		if err != nil {
+			log.Printf("Failed to write upload result: %v", err)
+		}
+	}
 	if err != nil {
 		log.Fatalf("Failed to upload: %v", err)
 	}
 
+	if uploadedName != "" {
+		log.Printf("Download your restore from the files of Lagoon task %s: %s", t.TaskId, uploadedName)
+	} else {
+		log.Printf("Download your restore from the files of Lagoon task %s", t.TaskId)
+	}
+
+	err = task.WriteUploadResult(task.UploadResult{
+		Snapshot: t.Args.Snapshot(),
+		Archive:  archive.Name(),
+		Files:    fileCount,
+		Bytes:    archiveInfo.Size(),
+		Checksum: checksum,
+		Phases:   phases,
+	})
+	if err != nil {
+		log.Printf("Failed to write upload result: %v", err)
+	}
+
 	os.Exit(0)
 }
 
+// uploadDiffReport writes the differences between the restore and the diff snapshot to a report and
+// uploads it to the Lagoon task.
+func uploadDiffReport(t *task.RestoreTask, diff task.TreeDiff, restoreTarget string, archiveTarget string) {
+	name := filepath.Join(archiveTarget, fmt.Sprintf("diff-%s-%s.txt", shortSnapshot(t.DiffSnapshot), shortSnapshot(t.Args.Snapshot())))
+	f, err := os.Create(name)
+	if err != nil {
+		log.Fatalf("Failed to create diff report: %v", err)
+	}
+	_, err = fmt.Fprintf(f, "Changes of %s from snapshot %s to snapshot %s\n", t.Args.RestoreFilter, t.DiffSnapshot, t.Args.Snapshot())
+	if err == nil {
+		err = task.WriteDiffReport(f, diff, restoreTarget, t.DiffTarget)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatalf("Failed to write diff report: %v", err)
+	}
+
+	log.Printf("Uploading diff report %s to Lagoon task %s", filepath.Base(name), t.TaskId)
+	if _, _, err := t.UploadFilesToLagoon([]string{name}); err != nil {
+		log.Fatalf("Failed to upload diff report: %v", err)
+	}
+
+	if !t.DiffOnly {
+		return
+	}
+	err = task.WriteUploadResult(task.UploadResult{
+		Snapshot: t.Args.Snapshot(),
+		Archive:  filepath.Base(name),
+		Files:    len(diff.Added) + len(diff.Changed) + len(diff.Deleted),
+	})
+	if err != nil {
+		log.Printf("Failed to write upload result: %v", err)
+	}
+}
+
+// shortSnapshot shortens a snapshot ID like restic does.
+func shortSnapshot(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// uploadRestoredFiles uploads the restored files matching the upload files pattern individually
+// instead of an archive.
+func uploadRestoredFiles(t *task.RestoreTask, restoreTarget string, archiveTarget string) {
+	files, err := t.SelectUploadFiles(restoreTarget)
+	if err != nil {
+		log.Fatalf("Failed to select files to upload: %v", err)
+	}
+	if len(files) == 0 {
+		log.Fatalf("No restored files match %s", t.UploadFiles)
+	}
+
+	links, err := task.LinkUploadFiles(restoreTarget, filepath.Join(archiveTarget, fmt.Sprintf("files-%s", t.TaskId)), files)
+	if err != nil {
+		log.Fatalf("Failed to prepare files to upload: %v", err)
+	}
+
+	var size int64
+	for _, link := range links {
+		if info, err := os.Stat(link); err == nil {
+			size += info.Size()
+		}
+	}
+
+	t.Section("upload")
+	log.Printf("Uploading %d files (%s) matching %s from snapshot %s to Lagoon task %s", len(files), humanize.Bytes(uint64(size)), t.UploadFiles, t.Args.Snapshot(), t.TaskId)
+
+	var phases []task.Phase
+	var uploaded, failed []string
+	uploadErr := task.TimePhase(&phases, "upload", func() error {
+		var err error
+		uploaded, failed, err = t.UploadFilesToLagoon(links)
+		return err
+	})
+
+	// Report the uploaded and failed files, also when the upload failed, so the parent task can
+	// tell which files are available.
+	err = task.WriteUploadResult(task.UploadResult{
+		Snapshot: t.Args.Snapshot(),
+		Files:    len(uploaded),
+		Bytes:    size,
+		Uploaded: uploaded,
+		Failed:   failed,
+		Phases:   phases,
+	})
+	if err != nil {
+		log.Printf("Failed to write upload result: %v", err)
+	}
+
+	if uploadErr != nil {
+		if len(uploaded) > 0 {
+			log.Printf("Uploaded files kept on Lagoon task %s: %s", t.TaskId, strings.Join(uploaded, ", "))
+		}
+		log.Fatalf("Failed to upload %s: %v", strings.Join(failed, ", "), uploadErr)
+	}
+
+	log.Printf("Download your restored files from the files of Lagoon task %s: %s", t.TaskId, strings.Join(uploaded, ", "))
+}
+
 type BootstrapResult struct {
 	uploadPod *corev1.Pod
+	Upload    *task.UploadResult
 	Cleanup   func()
 }
 
-// BootstrapUploadPod creates a new pod with the restore PVC, a PVC to save the archived files, and
-// runs the `upload` sub-subcommand.
-func BootstrapUploadPod(t *task.RestoreTask, taskImage string, restoreTarget string, restorePVC *corev1.PersistentVolumeClaim, archiveTarget string) (*BootstrapResult, error) {
-	uploadPodImageName := taskImage
+// taskPodImage determines the image of pods started by the task, preferring the image of the
+// running task pod.
+func taskPodImage(t *task.RestoreTask, taskImage string) (string, error) {
+	image := taskImage
 	var self corev1.Pod
 	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: os.Getenv("PODNAME")}, &self); err == nil {
-		uploadPodImageName = self.Spec.Containers[0].Image
+		image = self.Spec.Containers[0].Image
+	}
+	if image == "" {
+		return "", fmt.Errorf("failed to determine task image")
 	}
+	return image, nil
+}
+
+// uploadCommand builds the upload pod command, passing on flags that affect the upload.
+func uploadCommand(t *task.RestoreTask, restoreTarget string, archiveTarget string) []string {
+	command := []string{
+		"/usr/local/bin/restore-files-task",
+		"-restore-target", restoreTarget,
+		"-archive-target", archiveTarget,
+		"-on-empty", t.OnEmpty,
+		"-output-format", t.OutputFormat,
+		"-list-files", strconv.Itoa(t.ListFiles),
+		"-symlinks", t.Symlinks,
+	}
+
+	if t.ArchiveMode != 0 {
+		command = append(command, "-archive-mode", fmt.Sprintf("%04o", t.ArchiveMode))
+	}
+
+	if t.ArchiveUID >= 0 {
+		owner := strconv.Itoa(t.ArchiveUID)
+		if t.ArchiveGID >= 0 {
+			owner += ":" + strconv.Itoa(t.ArchiveGID)
+		}
+		command = append(command, "-archive-owner", owner)
+	}
+
+	for _, recipient := range t.EncryptRecipients {
+		command = append(command, "-encrypt", recipient)
+	}
+
+	for _, id := range t.AdditionalTaskIds {
+		command = append(command, "-additional-task-id", id)
+	}
+
+	if t.Strict {
+		command = append(command, "-strict")
+	}
+
+	if t.Rsyncable {
+		command = append(command, "-rsyncable")
+	}
+
+	if t.ArchivePVC != "" {
+		command = append(command, "-archive-pvc", t.ArchivePVC)
+	}
+
+	if t.OutputFormat == task.OutputFormatFiles {
+		command = append(command, "-upload-files", t.UploadFiles, "-max-upload-files", strconv.Itoa(t.MaxUploadFiles), "-upload-atomicity", t.UploadAtomicity)
+	}
+
+	if t.UploadTimeout > 0 {
+		command = append(command, "-upload-timeout", t.UploadTimeout.String())
+	}
+
+	if t.ArchiveFormat != task.ArchiveFormatTarGz {
+		command = append(command, "-archive-format", t.ArchiveFormat)
+	}
+
+	if t.CompressionLevel != 0 {
+		command = append(command, "-compression-level", strconv.Itoa(t.CompressionLevel))
+	}
+
+	if t.CompressionThreads > 1 {
+		command = append(command, "-compression-threads", strconv.Itoa(t.CompressionThreads), "-compression-block-size", strconv.Itoa(t.CompressionBlockSize))
+	}
+
+	if t.ExcludeHidden {
+		command = append(command, "-exclude-hidden")
+	}
+
+	for _, pattern := range t.ExcludePaths {
+		command = append(command, "-exclude-path", pattern)
+	}
+
+	for _, ext := range t.IncludeExtensions {
+		command = append(command, "-include-ext", ext)
+	}
+
+	for _, ext := range t.ExcludeExtensions {
+		command = append(command, "-exclude-ext", ext)
+	}
+
+	if t.LogSections {
+		command = append(command, "-log-sections")
+	}
+
+	if t.LogFormat == task.LogFormatJSON {
+		command = append(command, "-log-format", task.LogFormatJSON)
+	}
+
+	if t.SparseFiles != "" {
+		command = append(command, "-sparse-files", t.SparseFiles)
+	}
+
+	if t.CaseCollisions != "" {
+		command = append(command, "-case-collisions", t.CaseCollisions)
+	}
+
+	if t.ExpectedFiles >= 0 {
+		command = append(command, "-expected-files", strconv.Itoa(t.ExpectedFiles), "-file-count-tolerance", strconv.Itoa(t.FileCountTolerance))
+	}
+
+	if t.Reproducible {
+		command = append(command, "-reproducible")
+	}
+
+	if t.NoInfoFile {
+		command = append(command, "-no-info-file")
+	}
+
+	if t.IntegrityCheck {
+		command = append(command, "-integrity-check", "-integrity-check-percent", strconv.Itoa(t.IntegrityCheckPercent))
+	}
+
+	if t.VerifySampleFiles > 0 {
+		command = append(command, "-verify-sample", strconv.Itoa(t.VerifySampleFiles))
+	} else if t.VerifySamplePercent > 0 {
+		command = append(command, "-verify-sample", fmt.Sprintf("%d%%", t.VerifySamplePercent))
+	}
+
+	if t.VerifyArchive {
+		command = append(command, "-verify-archive")
+	}
+
+	if t.DiffPVC != "" {
+		command = append(command, "-diff-target", t.DiffTarget)
+	}
+
+	if t.DiffSnapshot != "" {
+		command = append(command, "-diff-snapshot", t.DiffSnapshot)
+		if t.DiffOnly {
+			command = append(command, "-diff-only")
+		}
+	}
+
+	return append(command, "upload")
+}
+
+// BootstrapUploadPod creates a new pod with the restore PVC, a PVC to save the archived files, and
+// runs the `upload` sub-subcommand.
+func BootstrapUploadPod(t *task.RestoreTask, taskImage string, uploadImage string, restoreTarget string, restorePVC *corev1.PersistentVolumeClaim, archiveTarget string) (*BootstrapResult, error) {
+	uploadPodImageName := uploadImage
 	if uploadPodImageName == "" {
-		return &BootstrapResult{}, fmt.Errorf("failed to determine task image")
+		image, err := taskPodImage(t, taskImage)
+		if err != nil {
+			return &BootstrapResult{}, err
+		}
+		uploadPodImageName = image
 	}
 
 	// Load the Schedule resource to get restic config.
-	var schedule k8upv1.Schedule
-	if err := t.Client.Get(t.Ctx, client.ObjectKey{
-		Name: "k8up-lagoon-backup-schedule",
-	}, &schedule); err != nil {
+	schedule, err := t.GetSchedule()
+	if err != nil {
 		return &BootstrapResult{}, fmt.Errorf("failed to get schedule: %w", err)
 	}
 
@@ -88,19 +510,36 @@ func BootstrapUploadPod(t *task.RestoreTask, taskImage string, restoreTarget str
 		return &BootstrapResult{}, fmt.Errorf("failed to marshal task args: %w", err)
 	}
 
-	archivePVC, err := t.CreateRestorePVC(fmt.Sprintf("archive-target-%s", t.TaskKey), "1Gi")
-	if err != nil {
-		t.Cleanup(&archivePVC, nil, nil)
-		return &BootstrapResult{}, fmt.Errorf("failed to create archive destination: %v", err)
+	// A shared archive PVC is reused by every run and never removed.
+	var archivePVC corev1.PersistentVolumeClaim
+	var removeArchivePVC *corev1.PersistentVolumeClaim
+	if t.ArchivePVC != "" {
+		archivePVC, err = t.LockArchivePVC()
+		if err != nil {
+			return &BootstrapResult{}, err
+		}
+	} else {
+		archivePVC, err = t.CreateRestorePVC(fmt.Sprintf("archive-target-%s", t.TaskKey), t.PVCSize)
+		if err != nil {
+			t.Cleanup(&archivePVC, nil, nil)
+			return &BootstrapResult{}, fmt.Errorf("failed to create archive destination: %w", err)
+		}
+		removeArchivePVC = &archivePVC
+	}
+
+	// The lock of a shared archive PVC is released once the upload pod is removed.
+	cleanupUpload := func(pvc *corev1.PersistentVolumeClaim, pod *corev1.Pod) {
+		t.Cleanup(pvc, nil, pod)
+		if t.ArchivePVC != "" {
+			t.UnlockArchivePVC()
+		}
 	}
 
 	var defaultMode int32 = 420
 	var pod = corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: fmt.Sprintf("upload-%s", t.TaskKey),
-			Annotations: map[string]string{
-				"k8up.io/backup": "false", // Ensure backups skip this pod.
-			},
+			Name:        fmt.Sprintf("upload-%s", t.TaskKey),
+			Annotations: task.BackupExcludedAnnotations(),
 		},
 		Spec: corev1.PodSpec{
 			Volumes: []corev1.Volume{
@@ -134,7 +573,7 @@ func BootstrapUploadPod(t *task.RestoreTask, taskImage string, restoreTarget str
 				{
 					Name:    "uploader",
 					Image:   uploadPodImageName,
-					Command: []string{"/usr/local/bin/restore-files-task", "upload"},
+					Command: uploadCommand(t, restoreTarget, archiveTarget),
 					Env: []corev1.EnvVar{
 						{
 							Name:  "JSON_PAYLOAD",
@@ -175,25 +614,52 @@ func BootstrapUploadPod(t *task.RestoreTask, taskImage string, restoreTarget str
 				},
 			},
 			RestartPolicy:      corev1.RestartPolicyNever,
-			ServiceAccountName: "lagoon-deployer",
+			ServiceAccountName: t.PodServiceAccount(),
+			PriorityClassName:  t.PriorityClass,
 		},
 	}
 
+	// Mount the live files read-only to diff the restore against.
+	if t.DiffPVC != "" {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: "diff-target",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: t.DiffPVC,
+					ReadOnly:  true,
+				},
+			},
+		})
+		pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      "diff-target",
+			ReadOnly:  true,
+			MountPath: t.DiffTarget,
+		})
+	}
+
 	// Run as same user as the backups and services.
 	if schedule.Spec.PodSecurityContext != nil {
 		pod.Spec.SecurityContext = schedule.Spec.PodSecurityContext
 	}
 
+	// Nothing is archived without the upload pod, so the archive PVC is removed even with
+	// KeepArchiveOnFailure.
 	err = t.Client.Create(context.TODO(), &pod)
 	if err != nil {
-		t.Cleanup(&archivePVC, nil, &pod)
-		return &BootstrapResult{}, fmt.Errorf("failed to create upload pod: %v", err)
+		cleanupUpload(removeArchivePVC, &pod)
+		return &BootstrapResult{}, fmt.Errorf("failed to create upload pod: %w", err)
 	}
 
+	// The upload pod may have archived the restore before it timed out or the task was aborted.
 	err = t.WaitForUpload(pod)
 	if err != nil {
-		t.Cleanup(&archivePVC, nil, &pod)
-		return &BootstrapResult{}, fmt.Errorf("failed to wait for upload: %v", err)
+		if t.KeepArchiveOnFailure {
+			log.Printf("Keeping archive pvc %s of the failed upload, it must be removed manually", archivePVC.Name)
+			cleanupUpload(nil, &pod)
+		} else {
+			cleanupUpload(removeArchivePVC, &pod)
+		}
+		return &BootstrapResult{}, fmt.Errorf("failed to wait for upload: %w", err)
 	}
 
 	// Determine if the upload was a succcess.
@@ -213,13 +679,43 @@ func BootstrapUploadPod(t *task.RestoreTask, taskImage string, restoreTarget str
 	}
 
 	if uploadFailed != nil {
-		t.Cleanup(&archivePVC, nil, &pod)
+		if uploadResult, err := task.ReadUploadResult(pod); err == nil && uploadResult.FailedCount > 0 {
+			log.Printf("Failed to upload %s, uploaded %d files: %s", task.FileList(uploadResult.Failed, uploadResult.FailedCount), uploadResult.UploadedCount, task.FileList(uploadResult.Uploaded, uploadResult.UploadedCount))
+		}
+		// Keep the archive to recover it manually or retry the upload without archiving again.
+		if uploadResult, err := task.ReadUploadResult(pod); err == nil && uploadResult.TaskClosed && t.OnClosedTask == task.OnClosedTaskKeepArchive {
+			log.Printf("Lagoon task %s is closed, keeping archive %s on pvc %s, it must be removed manually", t.TaskId, uploadResult.Archive, archivePVC.Name)
+			cleanupUpload(nil, &pod)
+		} else if t.KeepArchiveOnFailure {
+			log.Printf("Keeping archive pvc %s of the failed upload, it must be removed manually", archivePVC.Name)
+			cleanupUpload(nil, &pod)
+		} else {
+			cleanupUpload(removeArchivePVC, &pod)
+		}
 		return &BootstrapResult{}, fmt.Errorf("upload failed: %w", uploadFailed)
 	} else {
+		uploadResult, err := task.ReadUploadResult(pod)
+		if err != nil {
+			log.Printf("Failed to read upload result: %v", err)
+		}
+
+		// The archive PVC holds the output when the restored files are copied instead of uploaded.
+		if t.OutputFormat == task.OutputFormatDirectory {
+			log.Printf("Restored files are kept on pvc %s", archivePVC.Name)
+			return &BootstrapResult{
+				uploadPod: &pod,
+				Upload:    uploadResult,
+				Cleanup: func() {
+					cleanupUpload(nil, &pod)
+				},
+			}, nil
+		}
+
 		return &BootstrapResult{
 			uploadPod: &pod,
+			Upload:    uploadResult,
 			Cleanup: func() {
-				t.Cleanup(&archivePVC, nil, &pod)
+				cleanupUpload(removeArchivePVC, &pod)
 			},
 		}, nil
 	}
@@ -0,0 +1,119 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/amazeeio/lagoon-restore-files-task/internal/task"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ValidateRestore runs an application specific validation command in a pod with the restored files
+// mounted read-only. The restore is invalid when the command exits non-zero, its output is logged.
+func ValidateRestore(t *task.RestoreTask, image string, restoreTarget string, restorePVC *corev1.PersistentVolumeClaim, command string) error {
+	schedule, err := t.GetSchedule()
+	if err != nil {
+		return fmt.Errorf("failed to get schedule: %w", err)
+	}
+
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("validate-%s", t.TaskKey),
+			Annotations: task.BackupExcludedAnnotations(),
+		},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{
+					Name: "restore-target",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: restorePVC.Name,
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+			Containers: []corev1.Container{
+				{
+					Name:       "validate",
+					Image:      image,
+					Command:    []string{"sh", "-c", command},
+					WorkingDir: restoreTarget,
+					Env: []corev1.EnvVar{
+						{
+							Name:  "RESTORE_TARGET",
+							Value: restoreTarget,
+						},
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "restore-target",
+							ReadOnly:  true,
+							MountPath: restoreTarget,
+						},
+					},
+				},
+			},
+			RestartPolicy:      corev1.RestartPolicyNever,
+			ServiceAccountName: t.PodServiceAccount(),
+			PriorityClassName:  t.PriorityClass,
+		},
+	}
+
+	// Run as same user as the backups and services.
+	if schedule.Spec.PodSecurityContext != nil {
+		pod.Spec.SecurityContext = schedule.Spec.PodSecurityContext
+	}
+
+	log.Printf("Validating restored files with: %s", command)
+
+	if err := t.Client.Create(t.Ctx, &pod); err != nil {
+		return fmt.Errorf("failed to create validate pod: %w", err)
+	}
+	defer t.Cleanup(nil, nil, &pod)
+
+	if err := t.WaitForUpload(pod); err != nil {
+		return fmt.Errorf("failed to wait for validation: %w", err)
+	}
+
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: pod.Name}, &pod); err != nil {
+		return fmt.Errorf("failed to get validate pod: %w", err)
+	}
+
+	log.Println("====== Validation logs ======")
+	if err := t.PrintUploadLogs(pod); err != nil {
+		log.Printf("Failed to get logs: %v", err)
+	}
+
+	if pod.Status.Phase == corev1.PodFailed {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Terminated != nil {
+				return fmt.Errorf("validation command exited with code %d", status.State.Terminated.ExitCode)
+			}
+		}
+		return fmt.Errorf("validation failed: %w", errors.New(pod.Status.Message))
+	}
+
+	log.Println("Restored files are valid")
+
+	return nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// archivedNames returns the names of the entries of a tar.gz archive, failing on duplicate entries.
+func archivedNames(t *testing.T, path string) map[string]bool {
+	t.Helper()
+
+	archive, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	gz, err := gzip.NewReader(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gz.Close()
+
+	names := map[string]bool{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names[header.Name] {
+			t.Errorf("archive has duplicate entries %s", header.Name)
+		}
+		names[header.Name] = true
+	}
+}
+
+func TestArchiveRestoreIncludesDotfiles(t *testing.T) {
+	restoreTarget := t.TempDir()
+	for _, name := range []string{".env", ".htaccess", "index.php"} {
+		if err := os.WriteFile(filepath.Join(restoreTarget, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	task := &RestoreTask{
+		Args:       TaskArgs{BackupId: "1a2b3c4d"},
+		Ctx:        context.Background(),
+		TaskId:     "1",
+		OnEmpty:    OnEmptyFail,
+		Symlinks:   SymlinksPreserve,
+		NoInfoFile: true,
+		ArchiveUID: -1,
+		ArchiveGID: -1,
+	}
+	archive, count, err := task.ArchiveRestore(restoreTarget, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 3 {
+		t.Errorf("ArchiveRestore() archived %d files, want 3", count)
+	}
+	names := archivedNames(t, archive.Name())
+	for _, name := range []string{".env", ".htaccess", "index.php"} {
+		if !names[name] {
+			t.Errorf("archive is missing %s, has %v", name, names)
+		}
+	}
+}
+
+func TestArchiveRestoreRenamesCollidingInfoFile(t *testing.T) {
+	restoreTarget := t.TempDir()
+	if err := os.WriteFile(filepath.Join(restoreTarget, infoFileName), []byte("restored"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	task := &RestoreTask{
+		Args:       TaskArgs{BackupId: "1a2b3c4d"},
+		Ctx:        context.Background(),
+		TaskId:     "1",
+		OnEmpty:    OnEmptyFail,
+		Symlinks:   SymlinksPreserve,
+		ArchiveUID: -1,
+		ArchiveGID: -1,
+	}
+	archive, _, err := task.ArchiveRestore(restoreTarget, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := archivedNames(t, archive.Name())
+	for _, name := range []string{infoFileName, "RESTORE_INFO-t1.txt"} {
+		if !names[name] {
+			t.Errorf("archive is missing %s, has %v", name, names)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"archive/zip"
+	"fmt"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/mholt/archives"
+)
+
+const (
+	// ArchiveFormatTarGz is a gzip compressed tarball, the default.
+	ArchiveFormatTarGz = "tar.gz"
+	// ArchiveFormatTarZst is a zstd compressed tarball, faster with better ratios than gzip.
+	ArchiveFormatTarZst = "tar.zst"
+	// ArchiveFormatTarXz is an xz compressed tarball, the smallest but slowest.
+	ArchiveFormatTarXz = "tar.xz"
+	// ArchiveFormatTarBz2 is a bzip2 compressed tarball.
+	ArchiveFormatTarBz2 = "tar.bz2"
+	// ArchiveFormatZip is a zip archive, files which are already compressed are stored as is.
+	ArchiveFormatZip = "zip"
+)
+
+// ValidateArchiveFormat checks the archive format is known and can be combined with the compression
+// settings. Parallel and rsyncable compression are only supported for tar.gz.
+func ValidateArchiveFormat(format string, threads int, rsyncable bool) error {
+	switch format {
+	case ArchiveFormatTarGz:
+		return nil
+	case ArchiveFormatTarZst, ArchiveFormatTarXz, ArchiveFormatTarBz2, ArchiveFormatZip:
+	default:
+		return fmt.Errorf("unknown archive format %q, must be one of %s, %s, %s, %s or %s", format, ArchiveFormatTarGz, ArchiveFormatTarZst, ArchiveFormatTarXz, ArchiveFormatTarBz2, ArchiveFormatZip)
+	}
+
+	if threads > 1 {
+		return fmt.Errorf("%s archives can't be compressed with multiple threads", format)
+	}
+	if rsyncable {
+		return fmt.Errorf("%s archives can't be rsyncable", format)
+	}
+	return nil
+}
+
+// archiveFormat returns the archive format of the task, tar.gz when unset.
+func (t *RestoreTask) archiveFormat() string {
+	if t.ArchiveFormat == "" {
+		return ArchiveFormatTarGz
+	}
+	return t.ArchiveFormat
+}
+
+// archiver returns the archiver of the archive format with the compression settings of the task.
+// The compression level applies to tar.gz, tar.zst and tar.bz2.
+func (t *RestoreTask) archiver() archives.Archiver {
+	level := t.CompressionLevel
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	switch t.archiveFormat() {
+	case ArchiveFormatTarZst:
+		return archives.CompressedArchive{
+			Compression: archives.Zstd{EncoderOptions: []zstd.EOption{zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level))}},
+			Archival:    archives.Tar{},
+		}
+	case ArchiveFormatTarXz:
+		return archives.CompressedArchive{Compression: archives.Xz{}, Archival: archives.Tar{}}
+	case ArchiveFormatTarBz2:
+		return archives.CompressedArchive{Compression: archives.Bz2{CompressionLevel: level}, Archival: archives.Tar{}}
+	case ArchiveFormatZip:
+		return archives.Zip{Compression: zip.Deflate, SelectiveCompression: true}
+	default:
+		return archives.CompressedArchive{Compression: t.compression(), Archival: archives.Tar{}}
+	}
+}
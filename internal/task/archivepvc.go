@@ -0,0 +1,123 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// archiveLockAnnotation records the task key of the run using a shared archive PVC.
+const archiveLockAnnotation = "lagoon.sh/restore-archive-lock"
+
+// ArchivePVCLabel marks a PVC as a shared archive PVC, only PVCs labelled with the value true are
+// used, so a PVC of the environment passed by mistake is never cleared.
+const ArchivePVCLabel = "lagoon.sh/restore-archive"
+
+// archiveOutputPatterns match the files the task creates in the archive target: archives and copied
+// restores, diff reports and the links of individually uploaded files.
+var archiveOutputPatterns = []string{"restore-*", "diff-*.txt", "files-*"}
+
+// LockArchivePVC gets the shared archive PVC and marks it as used by this run. It fails when the
+// PVC is not labelled as an archive PVC, or the upload pod of another run still uses it. Locks of
+// runs without an upload pod are stale and taken over.
+func (t *RestoreTask) LockArchivePVC() (corev1.PersistentVolumeClaim, error) {
+	var pvc corev1.PersistentVolumeClaim
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: t.ArchivePVC}, &pvc); err != nil {
+		return pvc, fmt.Errorf("failed to get archive pvc %s: %w", t.ArchivePVC, err)
+	}
+
+	if pvc.Labels[ArchivePVCLabel] != "true" {
+		return pvc, fmt.Errorf("pvc %s is not labelled %s=true, only label a pvc used for nothing but restore archives, eg with: kubectl -n %s label pvc %s %s=true", pvc.Name, ArchivePVCLabel, t.Namespace, pvc.Name, ArchivePVCLabel)
+	}
+
+	if owner := pvc.Annotations[archiveLockAnnotation]; owner != "" && owner != t.TaskKey {
+		var pod corev1.Pod
+		err := t.Client.Get(t.Ctx, client.ObjectKey{Name: fmt.Sprintf("upload-%s", owner)}, &pod)
+		if err == nil {
+			return pvc, Transient(fmt.Errorf("archive pvc %s is in use by %s", pvc.Name, owner))
+		}
+		if !apierrors.IsNotFound(err) {
+			return pvc, fmt.Errorf("failed to check lock of archive pvc %s: %w", pvc.Name, err)
+		}
+		log.Printf("Taking over stale lock of archive pvc %s from %s", pvc.Name, owner)
+	}
+
+	if pvc.Annotations == nil {
+		pvc.Annotations = map[string]string{}
+	}
+	pvc.Annotations[archiveLockAnnotation] = t.TaskKey
+	// Updates fail with a conflict when another run locked the PVC since it was read.
+	if err := t.Client.Update(t.Ctx, &pvc); err != nil {
+		return pvc, fmt.Errorf("failed to lock archive pvc %s: %w", pvc.Name, err)
+	}
+
+	return pvc, nil
+}
+
+// UnlockArchivePVC releases the lock of this run on the shared archive PVC.
+func (t *RestoreTask) UnlockArchivePVC() {
+	var pvc corev1.PersistentVolumeClaim
+	if err := t.Client.Get(t.cleanupCtx(), client.ObjectKey{Name: t.ArchivePVC}, &pvc); err != nil {
+		log.Printf("Failed to unlock archive pvc %s: %v", t.ArchivePVC, err)
+		return
+	}
+	if pvc.Annotations[archiveLockAnnotation] != t.TaskKey {
+		return
+	}
+
+	delete(pvc.Annotations, archiveLockAnnotation)
+	if err := t.Client.Update(t.cleanupCtx(), &pvc); err != nil {
+		log.Printf("Failed to unlock archive pvc %s: %v", pvc.Name, err)
+	}
+}
+
+// ClearArchiveTarget removes the output of previous runs from a shared archive PVC. Only files
+// created by the task are removed, anything else on the PVC is kept.
+func ClearArchiveTarget(archiveTarget string) error {
+	entries, err := os.ReadDir(archiveTarget)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !isArchiveOutput(entry.Name()) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(archiveTarget, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isArchiveOutput determines if a file in the archive target was created by the task.
+func isArchiveOutput(name string) bool {
+	for _, pattern := range archiveOutputPatterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// setRestoreBackoffLimit sets the backoff limit of the restore job once k8up created it. The
+// Restore spec has no backoff limit, so the job is patched, k8up does not reset it afterwards.
+func (t *RestoreTask) setRestoreBackoffLimit(ctx context.Context, restore k8upv1.Restore) {
+	var job batchv1.Job
+	key := client.ObjectKey{Name: fmt.Sprintf("restore-%s", restore.Name)}
+	err := wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
+		return t.Client.Get(ctx, key, &job) == nil, nil
+	})
+	if err != nil {
+		return
+	}
+
+	patch := client.MergeFrom(job.DeepCopy())
+	job.Spec.BackoffLimit = t.RestoreBackoffLimit
+	if err := t.Client.Patch(ctx, &job, patch); err != nil {
+		log.Printf("Warning: failed to set backoff limit of restore job %s: %v", job.Name, err)
+		return
+	}
+	log.Printf("Set backoff limit of restore job %s to %d", job.Name, *t.RestoreBackoffLimit)
+}
+
+// startSettingRestoreBackoffLimit sets the restore job backoff limit in the background. The
+// returned function stops waiting for the job.
+func (t *RestoreTask) startSettingRestoreBackoffLimit(restore k8upv1.Restore) func() {
+	ctx, cancel := context.WithCancel(t.Ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t.setRestoreBackoffLimit(ctx, restore)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// DefaultBackupWaitTimeout is how long to wait for running backups with -wait-for-backup.
+const DefaultBackupWaitTimeout = time.Hour
+
+// backupAnnotation is the annotation k8up checks to skip PVCs and pods when backing up.
+const backupAnnotation = "k8up.io/backup"
+
+// BackupExcludedAnnotations returns the annotations of resources created by the task. Transient
+// restore data must never end up in the backups it is restored from.
+func BackupExcludedAnnotations() map[string]string {
+	return map[string]string{
+		backupAnnotation: "false",
+	}
+}
+
+// RunningBackups lists the k8up Backups in the namespace that have started but not finished.
+func (t *RestoreTask) RunningBackups() ([]string, error) {
+	var backups k8upv1.BackupList
+	if err := t.SourceClient.List(t.Ctx, &backups); err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var running []string
+	for _, backup := range backups.Items {
+		if backup.Status.Started && !backup.Status.HasFinished() {
+			running = append(running, backup.Name)
+		}
+	}
+
+	return running, nil
+}
+
+// WaitForBackups waits until no k8up Backups are running in the namespace. The wait ends early when
+// the task is aborted.
+func (t *RestoreTask) WaitForBackups(timeout time.Duration) error {
+	var running []string
+	err := wait.PollUntilContextTimeout(t.Ctx, 10*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		var err error
+		running, err = t.RunningBackups()
+		if err != nil {
+			return false, err
+		}
+		if len(running) > 0 {
+			log.Printf("Waiting for running backups to finish: %v", running)
+		}
+		return len(running) == 0, nil
+	})
+	if err != nil && t.Ctx.Err() == nil && len(running) > 0 {
+		return fmt.Errorf("backups still running after %s: %v", timeout, running)
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"testing"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestBackupExcludedAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+	}{
+		{name: "backup exclusion", annotations: BackupExcludedAnnotations()},
+		{name: "restore pvc", annotations: (&RestoreTask{}).restorePVCAnnotations()},
+		{
+			name: "restore pvc with custom annotations",
+			annotations: (&RestoreTask{
+				Args:           TaskArgs{BackupId: "1a2b3c4d", RestoreFilter: "/nginx"},
+				PVCAnnotations: map[string]string{"cost.example.com/project": "example"},
+			}).restorePVCAnnotations(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.annotations["k8up.io/backup"]; got != "false" {
+				t.Errorf("k8up.io/backup annotation = %q, want %q", got, "false")
+			}
+		})
+	}
+}
+
+func TestResourcesAreExcludedFromBackups(t *testing.T) {
+	task := newFakeTask(t, testSchedule())
+	task.ResticEnv = []corev1.EnvVar{{Name: "RESTIC_PACK_SIZE", Value: "64"}}
+	task.PVCAnnotations = map[string]string{"cost.example.com/project": "example"}
+
+	pvc, err := task.CreateRestorePVC("restore-target-rft-127", task.PVCSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archivePVC, err := task.CreateRestorePVC("archive-target-rft-127", task.PVCSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore, err := task.StartRestore(pvc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs := []client.Object{&pvc, &archivePVC, &restore}
+
+	// The restore job gets the custom restic env with a PodConfig.
+	if restore.Spec.PodConfigRef == nil {
+		t.Fatal("restore has no pod config")
+	}
+	objs = append(objs, &k8upv1.PodConfig{ObjectMeta: metav1.ObjectMeta{Name: restore.Spec.PodConfigRef.Name}})
+
+	starters := map[string]func(string) (corev1.Pod, error){
+		"list":    task.StartListPod,
+		"count":   task.StartCountPod,
+		"host":    task.StartHostPod,
+		"preview": task.StartPreviewPod,
+		"size":    task.StartSizePod,
+		"unlock":  task.StartUnlockPod,
+		"check": func(image string) (corev1.Pod, error) {
+			return task.StartCheckPod(image, 5)
+		},
+	}
+	for name, start := range starters {
+		pod, err := start(DefaultResticImage)
+		if err != nil {
+			t.Fatalf("failed to start %s pod: %v", name, err)
+		}
+		objs = append(objs, &pod)
+	}
+
+	for _, obj := range objs {
+		t.Run(obj.GetName(), func(t *testing.T) {
+			// Check the stored object, not the one the task built.
+			if err := task.Client.Get(task.Ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+				t.Fatal(err)
+			}
+			if got := obj.GetAnnotations()[backupAnnotation]; got != "false" {
+				t.Errorf("%T %s annotation = %q, want %q", obj, backupAnnotation, got, "false")
+			}
+		})
+	}
+}
+
+func TestParsePVCAnnotationsRejectsBackupAnnotation(t *testing.T) {
+	if _, err := ParsePVCAnnotations([]string{"k8up.io/backup=true"}, TargetData{}); err == nil {
+		t.Error("ParsePVCAnnotations() accepted an override of the k8up.io/backup annotation")
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/mholt/archives"
+)
+
+const (
+	// CaseCollisionsWarn archives files whose paths differ only in case as is, with a warning.
+	CaseCollisionsWarn = "warn"
+	// CaseCollisionsFail fails the archive when paths differ only in case.
+	CaseCollisionsFail = "fail"
+	// CaseCollisionsRename renames colliding files with a `~N` suffix before the extension.
+	CaseCollisionsRename = "rename"
+)
+
+// handleCaseCollisions detects files whose paths differ only in case, which overwrite each other
+// when the archive is extracted on a case-insensitive filesystem, eg on macOS or Windows.
+// Directories differing only in case are merged on extraction, so only collisions with files are
+// handled, files within such directories collide if their full paths do.
+func (t *RestoreTask) handleCaseCollisions(files []archives.FileInfo) ([]archives.FileInfo, error) {
+	seen := make(map[string]int, len(files))
+	var collisions int
+	for i, file := range files {
+		key := strings.ToLower(file.NameInArchive)
+		first, ok := seen[key]
+		if !ok {
+			seen[key] = i
+			continue
+		}
+		if file.IsDir() && files[first].IsDir() {
+			continue
+		}
+
+		collisions++
+		log.Printf("Warning: %s and %s differ only in case and collide on case-insensitive filesystems", files[first].NameInArchive, file.NameInArchive)
+		if t.CaseCollisions != CaseCollisionsRename {
+			continue
+		}
+
+		// Rename the file, a directory colliding with a file keeps its name so its contents don't
+		// move.
+		rename := i
+		if file.IsDir() {
+			rename = first
+		}
+		renamed := caseCollisionName(files[rename].NameInArchive, seen)
+		log.Printf("Renamed %s to %s in the archive", files[rename].NameInArchive, renamed)
+		files[rename].NameInArchive = renamed
+		seen[strings.ToLower(renamed)] = rename
+		if rename == first {
+			seen[key] = i
+		}
+	}
+
+	if collisions > 0 && t.CaseCollisions == CaseCollisionsFail {
+		return nil, fmt.Errorf("restore contains %d paths differing only in case", collisions)
+	}
+
+	return files, nil
+}
+
+// caseCollisionName returns an unused name for a file colliding with another, adding a `~N` suffix
+// before the extension, eg `Image~1.jpg`.
+func caseCollisionName(name string, seen map[string]int) string {
+	ext := path.Ext(name)
+	// Dotfiles like `.env` have no extension.
+	if ext == path.Base(name) {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+	for n := 1; ; n++ {
+		renamed := fmt.Sprintf("%s~%d%s", base, n, ext)
+		if _, ok := seen[strings.ToLower(renamed)]; !ok {
+			return renamed
+		}
+	}
+}
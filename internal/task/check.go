@@ -0,0 +1,30 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// StartCheckPod starts a pod reading and verifying a random percentage of the repository data with
+// `restic check --read-data-subset`. restic picks the subset from all packs of the repository, not
+// only those of the restored snapshot.
+func (t *RestoreTask) StartCheckPod(image string, percent int) (corev1.Pod, error) {
+	return t.startResticPod(fmt.Sprintf("check-%s", t.TaskKey), image, []string{"restic", "check", "--read-data-subset", fmt.Sprintf("%d%%", percent)})
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultCleanupBackoff is the backoff for removing the resources of the task.
+var DefaultCleanupBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    5,
+	Cap:      30 * time.Second,
+}
+
+// DeleteWithRetry removes a resource of the task, retrying transient API errors with the cleanup
+// backoff. A resource which is already gone is not an error.
+func (t *RestoreTask) DeleteWithRetry(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	err := retry.OnError(t.CleanupBackoff, IsTransient, func() error {
+		err := t.Client.Delete(ctx, obj, opts...)
+		if err != nil && !apierrors.IsNotFound(err) && IsTransient(err) {
+			log.Printf("Retrying removal of %s: %v", obj.GetName(), err)
+		}
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// warnOrphaned lists the resources which could not be removed, so they can be removed manually.
+func (t *RestoreTask) warnOrphaned(orphaned []string) {
+	if len(orphaned) == 0 {
+		return
+	}
+
+	log.Printf("WARNING: failed to clean up %d resources in namespace %s, they must be removed manually: %s", len(orphaned), t.Namespace, strings.Join(orphaned, ", "))
+	for _, resource := range orphaned {
+		log.Printf("WARNING:   kubectl -n %s delete %s", t.Namespace, resource)
+	}
+}
+
+// orphanedResource names a resource for warnOrphaned.
+func orphanedResource(kind string, obj client.Object) string {
+	return fmt.Sprintf("%s %s", kind, obj.GetName())
+}
+
+// retainedPV returns the name of the PV bound to a PVC of the task when its reclaim policy is
+// Retain, so removing the PVC leaves the PV and its storage behind. With ReclaimRetainedPVs the
+// reclaim policy is set to Delete instead, so the storage is released with the PVC. A PV which
+// can't be read, eg without permission to get PVs, is assumed to be released.
+func (t *RestoreTask) retainedPV(ctx context.Context, pvc *corev1.PersistentVolumeClaim) string {
+	var current corev1.PersistentVolumeClaim
+	if err := t.Client.Get(ctx, client.ObjectKeyFromObject(pvc), &current); err != nil || current.Spec.VolumeName == "" {
+		return ""
+	}
+
+	var pv corev1.PersistentVolume
+	if err := t.ClusterClient.Get(ctx, client.ObjectKey{Name: current.Spec.VolumeName}, &pv); err != nil {
+		return ""
+	}
+	if pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimRetain {
+		return ""
+	}
+
+	if !t.ReclaimRetainedPVs {
+		return pv.Name
+	}
+
+	patch := client.MergeFrom(pv.DeepCopy())
+	pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimDelete
+	if err := t.ClusterClient.Patch(ctx, &pv, patch); err != nil {
+		log.Printf("Failed to set reclaim policy of pv %s to Delete: %v", pv.Name, err)
+		return pv.Name
+	}
+	log.Printf("Set reclaim policy of pv %s to Delete, its storage is released with pvc %s", pv.Name, pvc.Name)
+	return ""
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/uselagoon/machinery/api/lagoon"
+	lclient "github.com/uselagoon/machinery/api/lagoon/client"
+	"github.com/uselagoon/machinery/api/schema"
+)
+
+// Behaviours when the Lagoon task no longer accepts uploads.
+const (
+	OnClosedTaskFail        = "fail"
+	OnClosedTaskKeepArchive = "keep-archive"
+)
+
+// ErrTaskClosed is returned when the Lagoon task finished before the archive was uploaded.
+var ErrTaskClosed = errors.New("task is closed")
+
+// closedTaskStatuses are the statuses of Lagoon tasks which no longer accept uploads.
+var closedTaskStatuses = []schema.StatusTypes{
+	schema.Succeeded,
+	schema.Failed,
+	schema.Cancelled,
+	schema.Error,
+	schema.Complete,
+}
+
+// checkTaskOpen returns ErrTaskClosed when the Lagoon task has finished, eg because it timed out on
+// Lagoon's side while the restore ran. A task that can't be queried is assumed to be open, the
+// upload reports the actual error then.
+func checkTaskOpen(ctx context.Context, lc *lclient.Client, id string) error {
+	taskId, _ := strconv.Atoi(id)
+	result, err := lagoon.TaskByID(ctx, taskId, lc)
+	if err != nil {
+		return nil
+	}
+
+	for _, status := range closedTaskStatuses {
+		if strings.EqualFold(result.Status, string(status)) {
+			return fmt.Errorf("%w: Lagoon task %s is no longer accepting uploads (status %s), it may have timed out", ErrTaskClosed, id, strings.ToLower(result.Status))
+		}
+	}
+
+	return nil
+}
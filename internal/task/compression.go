@@ -0,0 +1,92 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/pgzip"
+	"github.com/mholt/archives"
+)
+
+const (
+	// DefaultCompressionBlockSize is the size of the blocks compressed in parallel.
+	DefaultCompressionBlockSize = 1 << 20
+	// MinCompressionBlockSize and MaxCompressionBlockSize limit the block size, larger blocks
+	// compress better but need more memory.
+	MinCompressionBlockSize = 64 << 10
+	MaxCompressionBlockSize = 64 << 20
+)
+
+// parallelGz is gzip compression of blocks in parallel. Memory use grows with the number and size
+// of the blocks, roughly twice their product.
+type parallelGz struct {
+	archives.Gz
+	blockSize int
+	blocks    int
+}
+
+func (gz parallelGz) OpenWriter(w io.Writer) (io.WriteCloser, error) {
+	zw, err := pgzip.NewWriterLevel(w, gz.CompressionLevel)
+	if err != nil {
+		return nil, err
+	}
+	if err := zw.SetConcurrency(gz.blockSize, gz.blocks); err != nil {
+		return nil, err
+	}
+	return zw, nil
+}
+
+// ValidateCompression checks the compression settings are valid and can be combined.
+func ValidateCompression(level int, threads int, blockSize int, rsyncable bool) error {
+	if level < gzip.BestSpeed || level > gzip.BestCompression {
+		return fmt.Errorf("invalid compression level %d, must be between %d and %d", level, gzip.BestSpeed, gzip.BestCompression)
+	}
+	if threads < 1 {
+		return fmt.Errorf("invalid compression threads %d, must be at least 1", threads)
+	}
+	if blockSize < MinCompressionBlockSize || blockSize > MaxCompressionBlockSize {
+		return fmt.Errorf("invalid compression block size %d, must be between 64KiB and 64MiB", blockSize)
+	}
+	if rsyncable && threads > 1 {
+		return fmt.Errorf("rsyncable archives can't be compressed with multiple threads")
+	}
+	return nil
+}
+
+// compression returns the compression of archives with the compression settings of the task.
+func (t *RestoreTask) compression() archives.Compression {
+	level := t.CompressionLevel
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	switch {
+	case t.Rsyncable:
+		return rsyncableGz{Gz: archives.Gz{CompressionLevel: level}}
+	case t.CompressionThreads > 1:
+		blockSize := t.CompressionBlockSize
+		if blockSize == 0 {
+			blockSize = DefaultCompressionBlockSize
+		}
+		return parallelGz{Gz: archives.Gz{CompressionLevel: level}, blockSize: blockSize, blocks: t.CompressionThreads}
+	default:
+		return archives.Gz{CompressionLevel: level}
+	}
+}
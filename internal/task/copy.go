@@ -0,0 +1,113 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Formats the restored files can be output in.
+const (
+	OutputFormatArchive   = "archive"
+	OutputFormatDirectory = "directory"
+	// OutputFormatFiles uploads selected restored files individually instead of an archive.
+	OutputFormatFiles = "files"
+)
+
+// CopyRestore copies the restored files uncompressed into a directory in the archive target,
+// preserving the tree structure, file modes and symlinks. It returns the directory and the number
+// of files copied.
+func (t *RestoreTask) CopyRestore(restoreTarget string, archiveTarget string) (string, int, error) {
+	if _, err := os.Stat(restoreTarget); err != nil {
+		return "", 0, fmt.Errorf("invalid restore target %s: %v", restoreTarget, err)
+	}
+
+	dest := filepath.Join(archiveTarget, fmt.Sprintf("restore-%s-t%s", t.Args.Snapshot(), t.TaskId))
+	fileCount := 0
+
+	err := filepath.WalkDir(restoreTarget, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(restoreTarget, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+
+		excluded := (t.ExcludeHidden && t.isHidden(filepath.ToSlash(rel))) || t.isExcludedPath(filepath.ToSlash(rel)) || t.isExcludedExt(rel, d.IsDir())
+		if rel != "." && excluded {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fileCount++
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			fileCount++
+			return copyFile(path, target, info.Mode().Perm())
+		default:
+			// Special files can't be copied meaningfully.
+			return nil
+		}
+	})
+	if err != nil {
+		return "", fileCount, fmt.Errorf("failed to copy restored files: %w", err)
+	}
+
+	return dest, fileCount, nil
+}
+
+func copyFile(src string, dest string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/dustin/go-humanize"
+)
+
+// TreeDiff lists the files that differ between a restored tree and the live files.
+type TreeDiff struct {
+	Added   []string
+	Changed []string
+	Deleted []string
+}
+
+// DiffTrees compares the restored files with the live files. Only the top level entries present in
+// the restore are compared, as the restore filter usually selects part of the live volume.
+func DiffTrees(restored string, live string) (TreeDiff, error) {
+	var diff TreeDiff
+
+	err := filepath.WalkDir(restored, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		rel, err := filepath.Rel(restored, path)
+		if err != nil {
+			return err
+		}
+
+		same, err := sameFile(path, filepath.Join(live, rel))
+		switch {
+		case os.IsNotExist(err):
+			diff.Added = append(diff.Added, rel)
+		case err != nil:
+			return err
+		case !same:
+			diff.Changed = append(diff.Changed, rel)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return diff, fmt.Errorf("failed to walk restored files: %w", err)
+	}
+
+	entries, err := os.ReadDir(restored)
+	if err != nil {
+		return diff, fmt.Errorf("failed to read restored files: %w", err)
+	}
+
+	for _, entry := range entries {
+		liveRoot := filepath.Join(live, entry.Name())
+		err := filepath.WalkDir(liveRoot, func(path string, d fs.DirEntry, err error) error {
+			if os.IsNotExist(err) && path == liveRoot {
+				return nil
+			}
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+
+			rel, err := filepath.Rel(live, path)
+			if err != nil {
+				return err
+			}
+
+			if _, err := os.Lstat(filepath.Join(restored, rel)); os.IsNotExist(err) {
+				diff.Deleted = append(diff.Deleted, rel)
+			}
+			return nil
+		})
+		if err != nil {
+			return diff, fmt.Errorf("failed to walk live files: %w", err)
+		}
+	}
+
+	return diff, nil
+}
+
+// sameFile compares the size and content of two files.
+func sameFile(a string, b string) (bool, error) {
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	if aInfo.Size() != bInfo.Size() {
+		return false, nil
+	}
+
+	aSum, err := ChecksumFile(a)
+	if err != nil {
+		return false, err
+	}
+	bSum, err := ChecksumFile(b)
+	if err != nil {
+		return false, err
+	}
+
+	return aSum == bSum, nil
+}
+
+// LogDiff logs the differences between the restored and live files.
+func LogDiff(diff TreeDiff) {
+	log.Printf("Restore diff: %d added, %d changed, %d deleted", len(diff.Added), len(diff.Changed), len(diff.Deleted))
+	for _, path := range diff.Added {
+		log.Printf("  + %s", path)
+	}
+	for _, path := range diff.Changed {
+		log.Printf("  ~ %s", path)
+	}
+	for _, path := range diff.Deleted {
+		log.Printf("  - %s", path)
+	}
+}
+
+// WriteDiffReport writes the differences between the restored files and the files they are compared
+// with, with their sizes.
+func WriteDiffReport(w io.Writer, diff TreeDiff, restored string, live string) error {
+	size := func(root string, path string) string {
+		info, err := os.Stat(filepath.Join(root, path))
+		if err != nil {
+			return "?"
+		}
+		return humanize.Bytes(uint64(info.Size()))
+	}
+
+	if _, err := fmt.Fprintf(w, "%d added, %d changed, %d deleted\n\n", len(diff.Added), len(diff.Changed), len(diff.Deleted)); err != nil {
+		return err
+	}
+	for _, path := range diff.Added {
+		if _, err := fmt.Fprintf(w, "+ %s (%s)\n", path, size(restored, path)); err != nil {
+			return err
+		}
+	}
+	for _, path := range diff.Changed {
+		if _, err := fmt.Fprintf(w, "~ %s (%s -> %s)\n", path, size(live, path), size(restored, path)); err != nil {
+			return err
+		}
+	}
+	for _, path := range diff.Deleted {
+		if _, err := fmt.Fprintf(w, "- %s (%s)\n", path, size(live, path)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DiffSnapshotTask returns a copy of the task restoring the diff snapshot with the same restore
+// filter, with its own resource names so both restores can run in the namespace.
+func (t *RestoreTask) DiffSnapshotTask() *RestoreTask {
+	dt := *t
+	dt.TaskKey = t.TaskKey + "-diff"
+	dt.Args.BackupId = t.DiffSnapshot
+	dt.Args.SnapshotId = ""
+	dt.Args.MergeBackupIds = nil
+	dt.RestoreMethods = []string{RestoreMethodFolder}
+	dt.Resume = false
+	return &dt
+}
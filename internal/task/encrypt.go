@@ -0,0 +1,80 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"filippo.io/age"
+)
+
+// ParseRecipients parses age recipients, eg `age1...` public keys.
+func ParseRecipients(keys []string) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+	for _, key := range keys {
+		recipient, err := age.ParseX25519Recipient(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient %q: %w", key, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// EncryptArchive encrypts the archive for the configured age recipients. The unencrypted archive
+// is removed, the encrypted archive has an additional `.age` extension.
+func (t *RestoreTask) EncryptArchive(archive *os.File) (*os.File, error) {
+	recipients, err := ParseRecipients(t.EncryptRecipients)
+	if err != nil {
+		return &os.File{}, err
+	}
+
+	in, err := os.Open(archive.Name())
+	if err != nil {
+		return &os.File{}, fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer in.Close()
+
+	encrypted, err := os.Create(archive.Name() + ".age")
+	if err != nil {
+		return &os.File{}, fmt.Errorf("failed to create encrypted archive: %v", err)
+	}
+	defer encrypted.Close()
+
+	if err := t.setArchivePermissions(encrypted); err != nil {
+		return &os.File{}, err
+	}
+
+	w, err := age.Encrypt(encrypted, recipients...)
+	if err != nil {
+		return &os.File{}, fmt.Errorf("failed to encrypt archive: %v", err)
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		return &os.File{}, fmt.Errorf("failed to encrypt archive: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return &os.File{}, fmt.Errorf("failed to encrypt archive: %v", err)
+	}
+
+	if err := os.Remove(archive.Name()); err != nil {
+		return &os.File{}, fmt.Errorf("failed to remove unencrypted archive: %v", err)
+	}
+
+	return encrypted, nil
+}
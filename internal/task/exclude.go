@@ -0,0 +1,147 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/mholt/archives"
+)
+
+// ValidateExcludePaths checks the exclude patterns are valid globs.
+func ValidateExcludePaths(patterns []string) error {
+	for _, pattern := range patterns {
+		if strings.Trim(pattern, "/") == "" {
+			return fmt.Errorf("invalid exclude pattern %q", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// isExcludedPath determines if a path relative to the restore root matches an exclude pattern.
+// Like restic excludes, patterns starting with `/` match the path from the snapshot root, including
+// everything below it, and other patterns match any element of the path, eg `*.log` or `cache`.
+func (t *RestoreTask) isExcludedPath(name string) bool {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	elements := strings.Split(name, "/")
+	for _, pattern := range t.ExcludePaths {
+		if rooted, ok := strings.CutPrefix(pattern, "/"); ok {
+			rooted = strings.TrimSuffix(rooted, "/")
+			depth := strings.Count(rooted, "/") + 1
+			if depth > len(elements) {
+				continue
+			}
+			if matched, _ := path.Match(rooted, strings.Join(elements[:depth], "/")); matched {
+				return true
+			}
+			continue
+		}
+
+		for _, element := range elements {
+			if matched, _ := path.Match(strings.TrimSuffix(pattern, "/"), element); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// excludePaths leaves files matching the exclude patterns out of the files to archive.
+func (t *RestoreTask) excludePaths(files []archives.FileInfo) []archives.FileInfo {
+	var included []archives.FileInfo
+	var excluded int
+	for _, file := range files {
+		if t.isExcludedPath(file.NameInArchive) {
+			excluded++
+			continue
+		}
+		included = append(included, file)
+	}
+
+	log.Printf("Excluded %d files and directories matching %s from the archive", excluded, strings.Join(t.ExcludePaths, ", "))
+	return included
+}
+
+// ParseExtensions normalizes file extensions to lower case with a leading dot, eg `SQL` to `.sql`.
+func ParseExtensions(extensions []string) ([]string, error) {
+	var parsed []string
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if ext == "." || strings.ContainsAny(ext, "/*?[") {
+			return nil, fmt.Errorf("invalid extension %q", ext)
+		}
+		parsed = append(parsed, ext)
+	}
+	return parsed, nil
+}
+
+// hasExtension determines if a file name ends with one of the extensions, case insensitive so
+// `.jpg` matches `IMAGE.JPG`. Extensions with multiple dots like `.tar.gz` are supported.
+func hasExtension(name string, extensions []string) bool {
+	name = strings.ToLower(path.Base(name))
+	for _, ext := range extensions {
+		if strings.HasSuffix(name, ext) && name != ext {
+			return true
+		}
+	}
+	return false
+}
+
+// isExcludedExt determines if a file is left out of the archive by the extension filters.
+// Directories are never excluded by extension.
+func (t *RestoreTask) isExcludedExt(name string, dir bool) bool {
+	if dir {
+		return false
+	}
+	if len(t.IncludeExtensions) > 0 && !hasExtension(name, t.IncludeExtensions) {
+		return true
+	}
+	return hasExtension(name, t.ExcludeExtensions)
+}
+
+// filterExtensions leaves files out of the files to archive by their extension. With included
+// extensions directories are left out too, they are created when extracting the included files.
+func (t *RestoreTask) filterExtensions(files []archives.FileInfo) []archives.FileInfo {
+	var included []archives.FileInfo
+	var count, excluded int
+	for _, file := range files {
+		if file.IsDir() {
+			if len(t.IncludeExtensions) == 0 {
+				included = append(included, file)
+			}
+			continue
+		}
+		if t.isExcludedExt(file.NameInArchive, false) {
+			excluded++
+			continue
+		}
+		count++
+		included = append(included, file)
+	}
+
+	log.Printf("Included %d files and excluded %d files by extension", count, excluded)
+	return included
+}
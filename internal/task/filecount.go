@@ -0,0 +1,80 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ReadFileCount reads the file count reported by a finished count pod.
+func ReadFileCount(pod corev1.Pod) (int, error) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated == nil || status.State.Terminated.Message == "" {
+			continue
+		}
+
+		count, err := strconv.Atoi(strings.TrimSpace(status.State.Terminated.Message))
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse file count: %w", err)
+		}
+		return count, nil
+	}
+
+	return 0, fmt.Errorf("count pod did not report a file count")
+}
+
+// CheckFileCount compares the number of restored regular files with the number of files in the
+// snapshot. A difference of more than tolerance percent of the expected files is a warning, or an
+// error in strict mode.
+func (t *RestoreTask) CheckFileCount(restoreTarget string) error {
+	restored := 0
+	err := filepath.WalkDir(restoreTarget, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			restored++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count restored files: %w", err)
+	}
+
+	diff := restored - t.ExpectedFiles
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff*100 <= t.FileCountTolerance*t.ExpectedFiles {
+		log.Printf("Restored %d of %d files in the snapshot", restored, t.ExpectedFiles)
+		return nil
+	}
+
+	err = fmt.Errorf("restored %d files but the snapshot contains %d (tolerance %d%%)", restored, t.ExpectedFiles, t.FileCountTolerance)
+	if t.Strict {
+		return err
+	}
+	log.Printf("Warning: %v, the restore may be incomplete", err)
+	return nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"log"
+	"path"
+	"strings"
+
+	"github.com/mholt/archives"
+)
+
+// isHidden determines if a path relative to the restore root is a dotfile or in a dot directory.
+// Dot directories of the restore filter itself are not considered hidden, so restoring eg
+// `/data/nginx/.config` with hidden files excluded still archives its regular files.
+func (t *RestoreTask) isHidden(name string) bool {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	filter := path.Clean(strings.Trim(t.Args.RestoreFilter, "/"))
+	if name == filter || strings.HasPrefix(filter, name+"/") {
+		return false
+	}
+	if rest, ok := strings.CutPrefix(name, filter+"/"); ok {
+		name = rest
+	}
+
+	for _, element := range strings.Split(name, "/") {
+		if strings.HasPrefix(element, ".") && element != "." && element != ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeHidden leaves dotfiles and dot directories out of the files to archive.
+func (t *RestoreTask) excludeHidden(files []archives.FileInfo) []archives.FileInfo {
+	var visible []archives.FileInfo
+	var hidden int
+	for _, file := range files {
+		if t.isHidden(file.NameInArchive) {
+			hidden++
+			continue
+		}
+		visible = append(visible, file)
+	}
+
+	if hidden > 0 {
+		log.Printf("Excluded %d hidden files and directories from the archive", hidden)
+	}
+	return visible
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// snapshotHost is a snapshot printed by `restic snapshots --json`.
+type snapshotHost struct {
+	ID       string `json:"id"`
+	Hostname string `json:"hostname"`
+}
+
+// StartHostPod starts a pod printing the snapshot with `restic snapshots --json`, read its host
+// with ReadSnapshotHost. k8up does not sync the host of snapshots, so it is read from the
+// repository.
+func (t *RestoreTask) StartHostPod(image string) (corev1.Pod, error) {
+	command := []string{"restic", "snapshots", "--json", "--no-lock", "--no-cache", t.Args.Snapshot()}
+
+	return t.startResticPod(fmt.Sprintf("host-%s", t.TaskKey), image, command)
+}
+
+// ReadSnapshotHost reads the restic host of the snapshot from a finished host pod.
+func (t *RestoreTask) ReadSnapshotHost(pod corev1.Pod) (string, error) {
+	stream, err := t.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{}).Stream(t.Ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get snapshots: %w", err)
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var snapshots []snapshotHost
+		// restic prints warnings before the snapshots, eg about the repository.
+		if err := json.Unmarshal(scanner.Bytes(), &snapshots); err != nil {
+			continue
+		}
+		if len(snapshots) == 0 {
+			return "", fmt.Errorf("snapshot %s not found in the repository", t.Args.Snapshot())
+		}
+		return snapshots[0].Hostname, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read snapshots: %w", err)
+	}
+
+	return "", fmt.Errorf("no snapshots printed for %s", t.Args.Snapshot())
+}
+
+// CheckSnapshotHost checks the snapshot was taken under the restic host of the environment. k8up
+// backs up with the namespace as host, snapshots of another host were taken before the environment
+// was renamed or migrated, or by another environment sharing the repository. restic restores
+// snapshots by ID regardless of their host, so only a resolved latest snapshot fails on a mismatch,
+// unless AllowHostMismatch is set. Snapshots restored by ID are only warned about.
+func (t *RestoreTask) CheckSnapshotHost(host string) error {
+	if host == "" || host == t.SourceNamespace {
+		return nil
+	}
+
+	if t.Args.BackupId == LatestSnapshot && !t.AllowHostMismatch {
+		return fmt.Errorf("latest snapshot %s was taken under host %s, not %s of this environment, eg before the environment was renamed or migrated; pass its ID or -allow-host-mismatch to restore it anyway", t.Args.Snapshot(), host, t.SourceNamespace)
+	}
+
+	log.Printf("Warning: snapshot %s was taken under host %s, not %s of this environment", t.Args.Snapshot(), host, t.SourceNamespace)
+	return nil
+}
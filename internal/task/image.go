@@ -0,0 +1,53 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// imagePattern matches image references, following the grammar of the distribution reference
+// package: an optional registry domain and port, a lowercase repository path, an optional tag and
+// an optional digest.
+var imagePattern = regexp.MustCompile(`^` +
+	`(?:(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+	`(?::[\w][\w.-]{0,127})?` +
+	`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,})?` +
+	`$`)
+
+// maxImageNameLength is the maximum length of the repository name of an image reference.
+const maxImageNameLength = 255
+
+// ValidateImage checks an image reference is valid, eg `registry.example.com:5000/org/image:tag`.
+func ValidateImage(image string) error {
+	if !imagePattern.MatchString(image) {
+		return fmt.Errorf("invalid image reference %q", image)
+	}
+
+	name, _, _ := strings.Cut(image, "@")
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	if len(name) > maxImageNameLength {
+		return fmt.Errorf("invalid image reference %q, the name is longer than %d characters", image, maxImageNameLength)
+	}
+
+	return nil
+}
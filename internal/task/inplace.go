@@ -0,0 +1,100 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FindDeploymentPVC returns the PVC mounted by a deployment. It fails if the deployment mounts no
+// PVC or more than one, so the restore target is never ambiguous.
+func (t *RestoreTask) FindDeploymentPVC(name string) (corev1.PersistentVolumeClaim, error) {
+	var deployment appsv1.Deployment
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: name}, &deployment); err != nil {
+		return corev1.PersistentVolumeClaim{}, fmt.Errorf("failed to get deployment %s: %w", name, err)
+	}
+
+	var claims []string
+	for _, volume := range deployment.Spec.Template.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			claims = append(claims, volume.PersistentVolumeClaim.ClaimName)
+		}
+	}
+
+	if len(claims) != 1 {
+		return corev1.PersistentVolumeClaim{}, fmt.Errorf("deployment %s must mount exactly one PVC, found %d: [%s]", name, len(claims), strings.Join(claims, ", "))
+	}
+
+	var pvc corev1.PersistentVolumeClaim
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: claims[0]}, &pvc); err != nil {
+		return corev1.PersistentVolumeClaim{}, fmt.Errorf("failed to get pvc %s: %w", claims[0], err)
+	}
+
+	return pvc, nil
+}
+
+// ScaleDeployment sets the replicas of a deployment and returns the previous replica count. It is
+// not cancelled when the task is aborted, so a deployment is always scaled back up.
+func (t *RestoreTask) ScaleDeployment(name string, replicas int32) (int32, error) {
+	ctx := t.cleanupCtx()
+
+	var deployment appsv1.Deployment
+	if err := t.Client.Get(ctx, client.ObjectKey{Name: name}, &deployment); err != nil {
+		return 0, fmt.Errorf("failed to get deployment %s: %w", name, err)
+	}
+
+	var previous int32 = 1
+	if deployment.Spec.Replicas != nil {
+		previous = *deployment.Spec.Replicas
+	}
+
+	deployment.Spec.Replicas = &replicas
+	if err := t.Client.Update(ctx, &deployment); err != nil {
+		return previous, fmt.Errorf("failed to scale deployment %s: %w", name, err)
+	}
+
+	return previous, nil
+}
+
+// DefaultScaleDownTimeout is how long to wait for the pods of a scaled down deployment to stop.
+const DefaultScaleDownTimeout = 5 * time.Minute
+
+// WaitForScaleDown waits until a deployment has no running pods. The wait ends early when the task
+// is aborted.
+func (t *RestoreTask) WaitForScaleDown(name string, timeout time.Duration) error {
+	var replicas int32
+	err := wait.PollUntilContextTimeout(t.Ctx, 5*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		var deployment appsv1.Deployment
+		if err := t.Client.Get(ctx, client.ObjectKey{Name: name}, &deployment); err != nil {
+			return false, fmt.Errorf("failed to get deployment %s: %w", name, err)
+		}
+		replicas = deployment.Status.Replicas
+		return replicas == 0, nil
+	})
+	if err != nil && t.Ctx.Err() == nil && replicas > 0 {
+		return fmt.Errorf("deployment %s still has %d pods after %s", name, replicas, timeout)
+	}
+	return err
+}
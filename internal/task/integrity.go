@@ -0,0 +1,117 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"log"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// manifestName is the name of sha256sum manifests included in backups.
+const manifestName = "SHA256SUMS"
+
+// CheckIntegrity verifies a random percentage of the restored files listed in any sha256sum manifests
+// found in the restore. It returns the number of verified and listed files, and an error listing any
+// mismatches. k8up does not expose restic's own restore verification, so files not covered by a
+// manifest can't be verified.
+func CheckIntegrity(restoreTarget string, percent int) (int, int, error) {
+	var manifests []string
+	err := filepath.WalkDir(restoreTarget, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && d.Name() == manifestName {
+			manifests = append(manifests, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to find checksum manifests: %w", err)
+	}
+
+	if len(manifests) == 0 {
+		log.Printf("Warning: no %s manifests found in the restore, nothing to verify", manifestName)
+		return 0, 0, nil
+	}
+
+	verified, listed := 0, 0
+	var mismatches []string
+	for _, manifest := range manifests {
+		n, l, bad, err := verifyManifest(manifest, percent)
+		if err != nil {
+			return verified, listed, err
+		}
+		verified += n
+		listed += l
+		mismatches = append(mismatches, bad...)
+	}
+
+	if len(mismatches) > 0 {
+		return verified, listed, fmt.Errorf("%d restored files do not match their checksum: %s", len(mismatches), strings.Join(mismatches, ", "))
+	}
+
+	return verified, listed, nil
+}
+
+// verifyManifest checks a random percentage of the files listed in a sha256sum manifest, relative
+// to the manifest dir.
+func verifyManifest(manifest string, percent int) (int, int, []string, error) {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to open manifest %s: %w", manifest, err)
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(manifest)
+	verified, listed := 0, 0
+	var mismatches []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		sum, name, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		// sha256sum marks binary mode files with a leading "*".
+		name = strings.TrimPrefix(strings.TrimPrefix(name, " "), "*")
+		path := filepath.Join(dir, name)
+
+		listed++
+		if percent < 100 && rand.IntN(100) >= percent {
+			continue
+		}
+
+		actual, err := ChecksumFile(path)
+		if err != nil || actual != strings.ToLower(sum) {
+			mismatches = append(mismatches, path)
+			continue
+		}
+		verified++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return verified, listed, mismatches, fmt.Errorf("failed to read manifest %s: %w", manifest, err)
+	}
+
+	return verified, listed, mismatches, nil
+}
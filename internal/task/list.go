@@ -0,0 +1,61 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"io/fs"
+	"log"
+	"path/filepath"
+)
+
+// DefaultListFiles is the default number of restored files logged.
+const DefaultListFiles = 20
+
+// LogRestoredFiles logs the paths of up to limit restored files, and how many more were restored.
+func LogRestoredFiles(restoreTarget string, limit int) error {
+	listed, more := 0, 0
+	err := filepath.WalkDir(restoreTarget, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		if listed < limit {
+			rel, err := filepath.Rel(restoreTarget, path)
+			if err != nil {
+				return err
+			}
+			log.Printf("  %s", rel)
+			listed++
+			return nil
+		}
+
+		more++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if more > 0 {
+		log.Printf("  ... and %d more files", more)
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"sort"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultResticImage is the image of the pod listing snapshot files.
+const DefaultResticImage = "restic/restic:0.17.3"
+
+// backendEnv returns the restic env of a backend, the repository and its credentials. k8up fills in
+// S3 endpoints, buckets and credentials the schedule leaves unset from the global settings of the
+// operator, which the task can't read, so they are taken from the GlobalS3 settings of the task.
+// It fails naming the setting when neither has it.
+func (t *RestoreTask) backendEnv(backend *k8upv1.Backend) ([]corev1.EnvVar, error) {
+	repository := backend.String()
+	credentials := backend.GetCredentialEnv()
+	if repository == "" || credentials == nil {
+		return nil, fmt.Errorf("schedule backend has no repository")
+	}
+
+	required := []string{"RESTIC_PASSWORD"}
+	if s3 := backend.S3; s3 != nil {
+		endpoint, bucket := s3.Endpoint, s3.Bucket
+		if endpoint == "" {
+			endpoint = t.GlobalS3Endpoint
+		}
+		if bucket == "" {
+			bucket = t.GlobalS3Bucket
+		}
+		if endpoint == "" || bucket == "" {
+			return nil, fmt.Errorf("schedule backend has no S3 endpoint or bucket, k8up uses its global settings which the task can't read, pass -global-s3-endpoint and -global-s3-bucket")
+		}
+		repository = fmt.Sprintf("s3:%s/%s", endpoint, bucket)
+		required = append(required, "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY")
+	}
+
+	for _, name := range required {
+		if credentials[name] != nil {
+			continue
+		}
+		if t.GlobalS3Secret == "" {
+			return nil, fmt.Errorf("schedule backend has no %s, k8up uses its global credentials which the task can't read, pass -global-s3-secret", name)
+		}
+		credentials[name] = &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: t.GlobalS3Secret},
+				Key:                  name,
+			},
+		}
+	}
+
+	env := []corev1.EnvVar{
+		{Name: "RESTIC_REPOSITORY", Value: repository},
+	}
+
+	names := make([]string, 0, len(credentials))
+	for name := range credentials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		env = append(env, corev1.EnvVar{Name: name, ValueFrom: credentials[name]})
+	}
+
+	return env, nil
+}
+
+// StartListPod starts a pod listing the files of the snapshot matching the restore filter with
+// `restic ls`, without restoring them. The listing is the log of the pod. restic only lists the
+// direct children of a directory filter without --recursive.
+func (t *RestoreTask) StartListPod(image string) (corev1.Pod, error) {
+	command := []string{"restic", "ls", "--recursive", "--no-lock", "--no-cache", t.Args.Snapshot()}
+	if t.Args.RestoreFilter != "" {
+		command = append(command, t.Args.RestoreFilter)
+	}
+
+	return t.startResticPod(fmt.Sprintf("list-%s", t.TaskKey), image, command)
+}
+
+// StartCountPod starts a pod counting the files of the snapshot matching the restore filter with
+// `restic ls`. The count is the termination message of the pod, read it with ReadFileCount.
+func (t *RestoreTask) StartCountPod(image string) (corev1.Pod, error) {
+	// A failed listing is marked in the output, it would otherwise count as zero files.
+	script := `(restic ls --json --recursive --no-lock --no-cache "$1" "$2" || echo failed) | ` +
+		`awk '/"type":"file"/ { n++ } /^failed$/ { exit 1 } END { print n + 0 }' > ` + terminationMessagePath
+	command := []string{"sh", "-c", script, "sh", t.Args.Snapshot(), t.Args.RestoreFilter}
+
+	return t.startResticPod(fmt.Sprintf("count-%s", t.TaskKey), image, command)
+}
+
+// startResticPod starts a pod running a restic command against the repository of the schedule.
+func (t *RestoreTask) startResticPod(name string, image string, command []string) (corev1.Pod, error) {
+	schedule, err := t.GetSchedule()
+	if err != nil {
+		return corev1.Pod{}, fmt.Errorf("failed to get schedule: %w", err)
+	}
+
+	backend, err := t.restoreBackend(schedule.Spec.Backend)
+	if err != nil {
+		return corev1.Pod{}, err
+	}
+	if backend == nil {
+		return corev1.Pod{}, fmt.Errorf("schedule has no backend")
+	}
+	env, err := t.backendEnv(backend)
+	if err != nil {
+		return corev1.Pod{}, err
+	}
+
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: BackupExcludedAnnotations(),
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:    "list",
+					Image:   image,
+					Command: command,
+					Env:     append(env, t.ResticEnv...),
+				},
+			},
+			RestartPolicy:     corev1.RestartPolicyNever,
+			PriorityClassName: t.PriorityClass,
+		},
+	}
+
+	// Run as same user as the backups and services.
+	if schedule.Spec.PodSecurityContext != nil {
+		pod.Spec.SecurityContext = schedule.Spec.PodSecurityContext
+	}
+
+	if err := t.Client.Create(t.Ctx, &pod); err != nil {
+		return corev1.Pod{}, fmt.Errorf("failed to create pod %s: %w", name, err)
+	}
+
+	return pod, nil
+}
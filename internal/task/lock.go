@@ -0,0 +1,87 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ErrRepositoryLocked is returned when the restore failed because another operation, or a stale
+// lock, holds an exclusive lock on the repository.
+var ErrRepositoryLocked = errors.New("repository is locked")
+
+// lockErrors are restic errors logged when the repository lock can't be acquired.
+var lockErrors = []string{
+	"unable to create lock",
+	"repository is already locked",
+}
+
+// lockLogLines is the number of log lines of each restore pod searched for lock errors.
+const lockLogLines int64 = 100
+
+// CheckRepositoryLock returns ErrRepositoryLocked when the logs of the restore pods show restic
+// failed to lock the repository. Lock failures are transient, the other operation may finish.
+func (t *RestoreTask) CheckRepositoryLock(restore k8upv1.Restore) error {
+	podList, err := t.Clientset.CoreV1().Pods(restore.Namespace).List(t.Ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("batch.kubernetes.io/job-name=restore-%s", restore.Name),
+	})
+	if err != nil {
+		return nil
+	}
+
+	for _, pod := range podList.Items {
+		if line := t.lockError(pod); line != "" {
+			return Transient(fmt.Errorf("%w: %s, wait for other operations on the repository to finish or check for a stale lock (-unlock removes stale locks)", ErrRepositoryLocked, line))
+		}
+	}
+
+	return nil
+}
+
+// lockError returns the first lock error in the log of a restore pod.
+func (t *RestoreTask) lockError(pod corev1.Pod) string {
+	tail := lockLogLines
+	stream, err := t.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{TailLines: &tail}).Stream(t.Ctx)
+	if err != nil {
+		return ""
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		for _, lockError := range lockErrors {
+			if strings.Contains(scanner.Text(), lockError) {
+				return string(redact([]byte(strings.TrimSpace(scanner.Text()))))
+			}
+		}
+	}
+
+	return ""
+}
+
+// StartUnlockPod starts a pod removing stale locks from the repository with `restic unlock`. Locks
+// of running operations are kept.
+func (t *RestoreTask) StartUnlockPod(image string) (corev1.Pod, error) {
+	return t.startResticPod(fmt.Sprintf("unlock-%s", t.TaskKey), image, []string{"restic", "unlock", "--no-cache"})
+}
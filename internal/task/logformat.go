@@ -0,0 +1,107 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// LogFormatText logs human readable lines, the default.
+	LogFormatText = "text"
+	// LogFormatJSON logs a JSON object per line for log shippers.
+	LogFormatJSON = "json"
+)
+
+// logPhase is the phase of the task set by Section, added to JSON logs.
+var logPhase atomic.Value
+
+// jsonLogOutput is where JSON logs are written, pod logs which are JSON logs are relayed to it.
+var jsonLogOutput io.Writer = os.Stderr
+
+// ValidateLogFormat checks the log format is known.
+func ValidateLogFormat(format string) error {
+	switch format {
+	case LogFormatText, LogFormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("unknown log format %q, must be text or json", format)
+	}
+}
+
+// SetupLogging switches the log output to the log format. JSON logs are written by slog, which the
+// log package writes through once it is the default logger, with the task ID, backup ID and phase
+// of the task as fields.
+func (t *RestoreTask) SetupLogging(format string) {
+	t.LogFormat = format
+	if format != LogFormatJSON {
+		return
+	}
+
+	handler := phaseHandler{Handler: slog.NewJSONHandler(jsonLogOutput, nil)}
+	slog.SetDefault(slog.New(handler).With("task_id", t.TaskId, "backup_id", t.Args.BackupId))
+}
+
+// phaseHandler adds the phase of the task to records. Messages of the log package starting with
+// `Warning: ` are logged at warn level.
+type phaseHandler struct {
+	slog.Handler
+}
+
+func (h phaseHandler) Handle(ctx context.Context, r slog.Record) error {
+	if msg, ok := strings.CutPrefix(r.Message, "Warning: "); ok && r.Level == slog.LevelInfo {
+		r.Level = slog.LevelWarn
+		r.Message = msg
+	}
+	if phase, ok := logPhase.Load().(string); ok {
+		r.AddAttrs(slog.String("phase", phase))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h phaseHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return phaseHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h phaseHandler) WithGroup(name string) slog.Handler {
+	return phaseHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// logRestoreProgress logs a condition of the restore, as fields with JSON logs.
+func (t *RestoreTask) logRestoreProgress(restore string, condition *metav1.Condition) {
+	if t.LogFormat != LogFormatJSON {
+		log.Printf("Restore progress: %s\n", condition.Message)
+		return
+	}
+
+	slog.Info("Restore progress",
+		"restore", restore,
+		"condition", condition.Type,
+		"status", string(condition.Status),
+		"reason", condition.Reason,
+		"progress", condition.Message,
+	)
+}
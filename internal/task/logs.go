@@ -0,0 +1,235 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// urlPattern matches URLs in logs, restic and k8up log backend and webhook URLs with credentials.
+var urlPattern = regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"']+`)
+
+// logFlushTimeout is how long to wait for a followed log stream to end after the restore completed.
+const logFlushTimeout = 10 * time.Second
+
+// DefaultLogConcurrency is the default number of pod logs streamed at the same time.
+const DefaultLogConcurrency = 4
+
+// redactWriter writes complete lines to w with URLs redacted, and an optional prefix. Lines which
+// are JSON objects, eg JSON logs of the upload pod, are written to json unchanged instead, when set.
+type redactWriter struct {
+	w      io.Writer
+	json   io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func newRedactWriter(w io.Writer) *redactWriter {
+	return &redactWriter{w: w}
+}
+
+// newPrefixedRedactWriter returns a redactWriter prefixing each line, eg with the pod name.
+func newPrefixedRedactWriter(w io.Writer, prefix string) *redactWriter {
+	return &redactWriter{w: w, prefix: []byte(prefix)}
+}
+
+// relayJSON writes lines which are JSON objects to w unchanged with JSON logs, instead of nesting
+// them as the message of a log line.
+func (t *RestoreTask) relayJSON(r *redactWriter, w io.Writer) *redactWriter {
+	if t.LogFormat == LogFormatJSON {
+		r.json = w
+	}
+	return r
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	r.buf = append(r.buf, p...)
+	for {
+		i := bytes.IndexByte(r.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := r.writeLine(r.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		r.buf = r.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes a remaining partial line.
+func (r *redactWriter) Flush() error {
+	if len(r.buf) == 0 {
+		return nil
+	}
+	err := r.writeLine(append(r.buf, '\n'))
+	r.buf = nil
+	return err
+}
+
+// writeLine writes a complete line to w, or to json if it is a JSON object.
+func (r *redactWriter) writeLine(line []byte) error {
+	if r.json != nil {
+		redacted := redact(line)
+		if trimmed := bytes.TrimSpace(redacted); bytes.HasPrefix(trimmed, []byte("{")) && json.Valid(trimmed) {
+			_, err := r.json.Write(redacted)
+			return err
+		}
+	}
+	_, err := r.w.Write(r.line(line))
+	return err
+}
+
+// line returns a redacted and prefixed line, so it is written in a single write.
+func (r *redactWriter) line(line []byte) []byte {
+	if len(r.prefix) == 0 {
+		return redact(line)
+	}
+	return append(append([]byte{}, r.prefix...), redact(line)...)
+}
+
+// lockedWriter serializes writes of concurrent pod log streams, so lines are never interleaved.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+func redact(line []byte) []byte {
+	return urlPattern.ReplaceAllFunc(line, func(url []byte) []byte {
+		scheme, _, _ := bytes.Cut(url, []byte("://"))
+		return append(scheme, []byte("://[REDACTED]")...)
+	})
+}
+
+// followRestoreLogs streams the logs of the restore job pod until the pod terminates or ctx is
+// cancelled. The job pod is created by k8up after the restore, so it retries until it exists.
+func (t *RestoreTask) followRestoreLogs(ctx context.Context, restore k8upv1.Restore) {
+	var pod *corev1.Pod
+	err := wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
+		podList, err := t.Clientset.CoreV1().Pods(restore.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("batch.kubernetes.io/job-name=restore-%s", restore.Name),
+		})
+		if err != nil || len(podList.Items) == 0 {
+			return false, nil
+		}
+		pod = &podList.Items[0]
+		// Logs are not available until the container started.
+		return pod.Status.Phase != corev1.PodPending, nil
+	})
+	if err != nil {
+		return
+	}
+
+	stream, err := t.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{Follow: true}).Stream(ctx)
+	if err != nil {
+		log.Printf("Failed to follow restore logs: %v", err)
+		return
+	}
+	defer stream.Close()
+
+	w := t.relayJSON(newRedactWriter(log.Writer()), jsonLogOutput)
+	defer w.Flush()
+
+	if _, err := io.Copy(w, stream); err != nil && ctx.Err() == nil {
+		log.Printf("Failed to follow restore logs: %v", err)
+	}
+}
+
+// startFollowingRestoreLogs follows the restore logs in the background. The returned function
+// stops following, giving the stream a moment to end on its own first.
+func (t *RestoreTask) startFollowingRestoreLogs(restore k8upv1.Restore) func() {
+	ctx, cancel := context.WithCancel(t.Ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t.followRestoreLogs(ctx, restore)
+	}()
+
+	return func() {
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(logFlushTimeout):
+		}
+		cancel()
+		wg.Wait()
+	}
+}
+
+// printPodLogs prints the logs of the pods, streaming up to LogConcurrency pods at the same time.
+// Each line is prefixed with the pod name so interleaved logs remain attributable.
+func (t *RestoreTask) printPodLogs(podList *corev1.PodList) {
+	concurrency := t.LogConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	out := &lockedWriter{w: log.Writer()}
+	jsonOut := &lockedWriter{w: jsonLogOutput}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, pod := range podList.Items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			t.printPodLog(pod, t.relayJSON(newPrefixedRedactWriter(out, pod.Name+": "), jsonOut))
+		}()
+	}
+	wg.Wait()
+}
+
+func (t *RestoreTask) printPodLog(pod corev1.Pod, w *redactWriter) {
+	stream, err := t.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{}).Stream(t.Ctx)
+	if err != nil {
+		log.Printf("Failed to get logs of pod %s: %v", pod.Name, err)
+		return
+	}
+	defer stream.Close()
+
+	if _, err := io.Copy(w, stream); err != nil {
+		log.Printf("Failed to print logs of pod %s: %v", pod.Name, err)
+	}
+	w.Flush()
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRedactWriterRelaysJSONLines(t *testing.T) {
+	var out, jsonOut bytes.Buffer
+	task := &RestoreTask{LogFormat: LogFormatJSON}
+	w := task.relayJSON(newPrefixedRedactWriter(&out, "upload: "), &jsonOut)
+
+	input := "Archiving restored files\n" +
+		`{"level":"INFO","msg":"Uploading","url":"https://user:pw@example.com"}` + "\n" +
+		"{not json\n"
+	if _, err := w.Write([]byte(input)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	wantJSON := `{"level":"INFO","msg":"Uploading","url":"https://[REDACTED]"}` + "\n"
+	if jsonOut.String() != wantJSON {
+		t.Errorf("relayed %q, want %q", jsonOut.String(), wantJSON)
+	}
+	wantOut := "upload: Archiving restored files\nupload: {not json\n"
+	if out.String() != wantOut {
+		t.Errorf("logged %q, want %q", out.String(), wantOut)
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"log"
+	"time"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// scheduleName is the k8up Schedule Lagoon creates for environment backups.
+const scheduleName = "k8up-lagoon-backup-schedule"
+
+// DefaultLookupBackoff is the backoff for the initial lookups of the task.
+var DefaultLookupBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    5,
+	Cap:      30 * time.Second,
+}
+
+// getWithRetry gets an object of the environment, retrying transient API errors with the lookup backoff.
+func (t *RestoreTask) getWithRetry(key client.ObjectKey, obj client.Object) error {
+	return retry.OnError(t.LookupBackoff, IsTransient, func() error {
+		err := t.SourceClient.Get(t.Ctx, key, obj)
+		if err != nil && IsTransient(err) {
+			log.Printf("Retrying lookup of %s: %v", key.Name, err)
+		}
+		return err
+	})
+}
+
+// watchWithRetry starts a watch, retrying transient API errors with the lookup backoff. Permanent
+// errors, eg a forbidden watch, are returned immediately.
+func (t *RestoreTask) watchWithRetry(list client.ObjectList, opts ...client.ListOption) (watch.Interface, error) {
+	retriable := func(err error) bool {
+		return t.Ctx.Err() == nil && IsTransient(err)
+	}
+
+	var w watch.Interface
+	err := retry.OnError(t.LookupBackoff, retriable, func() error {
+		var err error
+		w, err = t.WatchingClient.Watch(t.Ctx, list, opts...)
+		if err != nil && retriable(err) {
+			log.Printf("Retrying watch: %v", err)
+		}
+		return err
+	})
+	return w, err
+}
+
+// GetSchedule loads the Schedule resource which holds the restic config.
+func (t *RestoreTask) GetSchedule() (k8upv1.Schedule, error) {
+	var schedule k8upv1.Schedule
+	err := t.getWithRetry(client.ObjectKey{Name: scheduleName}, &schedule)
+	return schedule, err
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SplitBackupIds splits comma separated backup IDs into the backup ID and the merge backup IDs
+// restored on top of it.
+func SplitBackupIds(ids string) (string, []string, error) {
+	first, rest, ok := strings.Cut(ids, ",")
+	if !ok {
+		return ids, nil, nil
+	}
+
+	var merge []string
+	for _, id := range strings.Split(rest, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			return "", nil, fmt.Errorf("invalid backup ids %q, empty backup id", ids)
+		}
+		merge = append(merge, id)
+	}
+
+	first = strings.TrimSpace(first)
+	if first == "" {
+		return "", nil, fmt.Errorf("invalid backup ids %q, empty backup id", ids)
+	}
+	return first, merge, nil
+}
+
+// CheckMergeSnapshots ensures the resolved snapshots of the restore are distinct.
+func (t *RestoreTask) CheckMergeSnapshots() error {
+	seen := map[string]bool{t.Args.Snapshot(): true}
+	for _, id := range t.Args.MergeBackupIds {
+		if seen[id] {
+			return fmt.Errorf("snapshot %s is restored more than once", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// MergeSnapshotTask returns a copy of the task restoring the nth merge snapshot into the restore
+// PVC. Its task key is unique, so its restore, restore job and pod config don't collide with the
+// restore or each other.
+func (t *RestoreTask) MergeSnapshotTask(n int) *RestoreTask {
+	mt := *t
+	mt.TaskKey = fmt.Sprintf("%s-m%d", t.TaskKey, n+1)
+	mt.Args.BackupId = t.Args.MergeBackupIds[n]
+	mt.Args.SnapshotId = ""
+	mt.Args.MergeBackupIds = nil
+	mt.RestoreMethods = []string{RestoreMethodFolder}
+	mt.Resume = false
+	return &mt
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"strings"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// RestoreMethodFolder restores into a PVC which is archived and uploaded.
+	RestoreMethodFolder = "folder"
+	// RestoreMethodS3 restores into an S3 bucket as a tar.gz by k8up, the upload is skipped.
+	RestoreMethodS3 = "s3"
+)
+
+// ParseRestoreMethods parses a comma separated, ordered list of restore methods.
+func ParseRestoreMethods(list string) ([]string, error) {
+	var methods []string
+	for _, method := range strings.Split(list, ",") {
+		method = strings.TrimSpace(method)
+		switch method {
+		case RestoreMethodFolder, RestoreMethodS3:
+		default:
+			return nil, fmt.Errorf("unknown restore method %q, must be one of: folder, s3", method)
+		}
+		for _, m := range methods {
+			if m == method {
+				return nil, fmt.Errorf("duplicate restore method %q", method)
+			}
+		}
+		methods = append(methods, method)
+	}
+	return methods, nil
+}
+
+// folderRestoreMethod restores into the given PVC.
+func folderRestoreMethod(pvc corev1.PersistentVolumeClaim) *k8upv1.RestoreMethod {
+	return &k8upv1.RestoreMethod{
+		Folder: &k8upv1.FolderRestore{
+			PersistentVolumeClaimVolumeSource: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: pvc.Name,
+			},
+		},
+	}
+}
+
+// s3RestoreMethod restores into the configured S3 bucket. Empty settings fall back to the global
+// restore S3 settings of the k8up operator.
+func (t *RestoreTask) s3RestoreMethod() *k8upv1.RestoreMethod {
+	s3 := t.S3Restore
+	if s3 == nil {
+		s3 = &k8upv1.S3Spec{}
+	}
+	return &k8upv1.RestoreMethod{S3: s3}
+}
+
+// StartS3Restore creates a k8up Restore resource to restore files from a backup into S3.
+func (t *RestoreTask) StartS3Restore() (k8upv1.Restore, error) {
+	return t.startRestore(t.s3RestoreMethod())
+}
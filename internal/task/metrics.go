@@ -0,0 +1,102 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIMetrics counts and times the requests made to the Kubernetes API server.
+type APIMetrics struct {
+	mu    sync.Mutex
+	stats map[string]*apiStat
+}
+
+type apiStat struct {
+	count int
+	total time.Duration
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Wrap instruments a round tripper, it can be passed to rest.Config.Wrap.
+func (m *APIMetrics) Wrap(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := rt.RoundTrip(req)
+		m.record(requestVerb(req), time.Since(start))
+		return resp, err
+	})
+}
+
+func (m *APIMetrics) record(verb string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stats == nil {
+		m.stats = map[string]*apiStat{}
+	}
+	if m.stats[verb] == nil {
+		m.stats[verb] = &apiStat{}
+	}
+	m.stats[verb].count++
+	m.stats[verb].total += duration
+}
+
+// LogSummary logs the request count and latency per verb. Watch latency only covers establishing
+// the watch.
+func (m *APIMetrics) LogSummary() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	verbs := make([]string, 0, len(m.stats))
+	for verb := range m.stats {
+		verbs = append(verbs, verb)
+	}
+	sort.Strings(verbs)
+
+	log.Println("DEBUG: Kubernetes API requests")
+	for _, verb := range verbs {
+		stat := m.stats[verb]
+		log.Printf("DEBUG:   %-6s count=%d total=%s avg=%s", verb, stat.count, stat.total.Round(time.Millisecond), (stat.total / time.Duration(stat.count)).Round(time.Millisecond))
+	}
+}
+
+func requestVerb(req *http.Request) string {
+	switch req.Method {
+	case http.MethodGet:
+		if req.URL.Query().Get("watch") == "true" {
+			return "watch"
+		}
+		return "get"
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	default:
+		return strings.ToLower(req.Method)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultProtectedNamespaces are namespaces the task never creates resources in.
+const DefaultProtectedNamespaces = "default,kube-*"
+
+// CheckNamespace ensures the namespace does not match any of the comma separated protected
+// namespace patterns, which support `*` wildcards.
+func CheckNamespace(namespace string, protected string) error {
+	for _, pattern := range strings.Split(protected, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		matched, err := path.Match(pattern, namespace)
+		if err != nil {
+			return fmt.Errorf("invalid protected namespace pattern %q: %w", pattern, err)
+		}
+		if matched {
+			return fmt.Errorf("namespace %s is protected (%s), restores can't run in it", namespace, pattern)
+		}
+	}
+
+	return nil
+}
+
+// CheckNamespaceActive fails when the namespace is being deleted, eg because the environment is
+// removed right as the restore is triggered, instead of failing on the first resource it creates.
+// A namespace which can't be read, eg without permission to get namespaces, is assumed active.
+func (t *RestoreTask) CheckNamespaceActive(namespace string) error {
+	var ns corev1.Namespace
+	if err := t.ClusterClient.Get(t.Ctx, client.ObjectKey{Name: namespace}, &ns); err != nil {
+		return nil
+	}
+
+	return checkNamespaceActive(ns)
+}
+
+func checkNamespaceActive(ns corev1.Namespace) error {
+	if ns.Status.Phase == corev1.NamespaceTerminating || ns.DeletionTimestamp != nil {
+		return fmt.Errorf("namespace %s is being deleted — cannot run restore", ns.Name)
+	}
+	return nil
+}
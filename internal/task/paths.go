@@ -0,0 +1,120 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// TargetData are the values available to restore and archive target templates.
+type TargetData struct {
+	Namespace string
+	TaskID    string
+	Snapshot  string
+}
+
+// RenderTarget renders a target path template, eg `/work/{{.Namespace}}/{{.TaskID}}`, so runs
+// sharing a work volume use distinct paths. Environment variables are available with
+// `{{env "NAME"}}`. Paths without template actions are returned unchanged.
+func RenderTarget(target string, data TargetData) (string, error) {
+	if !strings.Contains(target, "{{") {
+		return target, nil
+	}
+
+	path, err := renderTemplate("target", target, data)
+	if err != nil {
+		return "", err
+	}
+	if strings.ContainsAny(path, "\n\r\x00") {
+		return "", fmt.Errorf("target template %s rendered to an invalid path %q", target, path)
+	}
+	for _, part := range strings.Split(path, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("target template %s rendered to a path with .. %q", target, path)
+		}
+	}
+
+	return path, nil
+}
+
+// renderTemplate renders a template of task values, with environment variables available with
+// `{{env "NAME"}}`.
+func renderTemplate(kind string, text string, data TargetData) (string, error) {
+	tmpl, err := template.New(kind).Option("missingkey=error").Funcs(template.FuncMap{"env": os.Getenv}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template %s: %w", kind, text, err)
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template %s: %w", kind, text, err)
+	}
+
+	return rendered.String(), nil
+}
+
+// NormalizeTargets cleans the restore and archive target paths and ensures they can be used
+// together. When the targets are used as pod mount paths they must be absolute, otherwise relative
+// paths are resolved against the working directory. The targets must be distinct and not nested,
+// so the archive can never include itself.
+func NormalizeTargets(restoreTarget string, archiveTarget string, requireAbs bool) (string, string, error) {
+	targets := []string{restoreTarget, archiveTarget}
+	for i, target := range targets {
+		if target == "" {
+			return "", "", fmt.Errorf("target path must not be empty")
+		}
+
+		if !filepath.IsAbs(target) {
+			if requireAbs {
+				return "", "", fmt.Errorf("target path %s must be absolute", target)
+			}
+
+			abs, err := filepath.Abs(target)
+			if err != nil {
+				return "", "", fmt.Errorf("invalid target path %s: %w", target, err)
+			}
+			target = abs
+		}
+
+		targets[i] = filepath.Clean(target)
+	}
+
+	if targets[0] == targets[1] {
+		return "", "", fmt.Errorf("restore target and archive target must be different paths: %s", targets[0])
+	}
+
+	if isWithin(targets[0], targets[1]) || isWithin(targets[1], targets[0]) {
+		return "", "", fmt.Errorf("restore target %s and archive target %s must not be nested", targets[0], targets[1])
+	}
+
+	return targets[0], targets[1], nil
+}
+
+// isWithin determines if path is inside of dir.
+func isWithin(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
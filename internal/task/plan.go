@@ -0,0 +1,104 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PlanRestore prints the parameters of the restore the task would submit, without creating any
+// resources. It ensures the schedule exists and the credentials of its backend can be read.
+func (t *RestoreTask) PlanRestore() error {
+	schedule, err := t.GetSchedule()
+	if err != nil {
+		return fmt.Errorf("failed to get schedule: %w", err)
+	}
+
+	backend, err := t.restoreBackend(schedule.Spec.Backend)
+	if err != nil {
+		return err
+	}
+	if backend == nil {
+		return fmt.Errorf("schedule has no backend")
+	}
+	if err := t.checkBackendCredentials(backend); err != nil {
+		return err
+	}
+	// Only the pods running restic need the repository settings, k8up fills in its global settings.
+	if _, err := t.backendEnv(backend); err != nil {
+		log.Printf("Warning: pods running restic for -list-only, -verify-file-count or -unlock can't be started: %v", err)
+	}
+
+	method := t.s3RestoreMethod()
+	if t.RestoreMethods[0] == RestoreMethodFolder {
+		pvc := corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("restore-target-%s", t.TaskKey)}}
+		method = folderRestoreMethod(pvc)
+		log.Printf("Would create pvc %s (%s, storage class %s)", pvc.Name, t.PVCSize, t.storageClassDescription())
+	}
+	log.Printf("Would create restore %s", t.TaskKey)
+	for i, id := range t.Args.MergeBackupIds {
+		log.Printf("Would create restore %s-m%d merging snapshot %s", t.TaskKey, i+1, id)
+	}
+	if t.DiffSnapshot != "" {
+		log.Printf("Would create restore %s-diff of diff snapshot %s", t.TaskKey, t.DiffSnapshot)
+	}
+	fmt.Println()
+
+	t.logRestoreParameters(backend, method, t.restoreEnv(backend))
+	return nil
+}
+
+// checkBackendCredentials ensures the secrets holding the credentials of the backend exist and
+// contain their keys. Service accounts which may not read secrets skip the check.
+func (t *RestoreTask) checkBackendCredentials(backend *k8upv1.Backend) error {
+	credentials := backend.GetCredentialEnv()
+	names := make([]string, 0, len(credentials))
+	for name := range credentials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		source := credentials[name]
+		if source == nil || source.SecretKeyRef == nil {
+			continue
+		}
+
+		var secret corev1.Secret
+		err := t.SourceClient.Get(t.Ctx, client.ObjectKey{Name: source.SecretKeyRef.Name}, &secret)
+		if apierrors.IsForbidden(err) {
+			log.Printf("Warning: can't check backend credentials in secret %s: %v", source.SecretKeyRef.Name, err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get backend credentials %s: %w", name, err)
+		}
+		if _, ok := secret.Data[source.SecretKeyRef.Key]; !ok {
+			return fmt.Errorf("backend credentials %s: secret %s has no key %s", name, secret.Name, source.SecretKeyRef.Key)
+		}
+	}
+
+	return nil
+}
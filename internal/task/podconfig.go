@@ -0,0 +1,303 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+var resticEnvKey = regexp.MustCompile(`^RESTIC_[A-Z0-9_]+$`)
+
+// managedResticEnv are restic env vars set by k8up from the backend config which can't be overridden.
+var managedResticEnv = map[string]bool{
+	"RESTIC_REPOSITORY":       true,
+	"RESTIC_PASSWORD":         true,
+	"RESTIC_PASSWORD_FILE":    true,
+	"RESTIC_PASSWORD_COMMAND": true,
+}
+
+// ParseResticEnv parses KEY=VALUE pairs into env vars for the restore job. Only RESTIC_* variables
+// not already managed by k8up are supported, eg RESTIC_PACK_SIZE or RESTIC_CACHE_DIR.
+func ParseResticEnv(pairs []string) ([]corev1.EnvVar, error) {
+	var env []corev1.EnvVar
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid restic env %q, must be KEY=VALUE", pair)
+		}
+
+		if !resticEnvKey.MatchString(key) {
+			return nil, fmt.Errorf("invalid restic env %s, only RESTIC_* variables are supported", key)
+		}
+
+		if managedResticEnv[key] {
+			return nil, fmt.Errorf("invalid restic env %s, it is managed by k8up", key)
+		}
+
+		env = append(env, corev1.EnvVar{Name: key, Value: value})
+	}
+
+	return env, nil
+}
+
+// ParseResticArgs parses restic arguments into restic options of the restore job. k8up only passes
+// restic options (`-o KEY=VALUE` or `--option KEY=VALUE`) on to restic, as comma separated
+// RESTIC_OPTIONS, so other arguments and options containing commas are rejected.
+func ParseResticArgs(args []string) ([]string, error) {
+	var options []string
+	for _, arg := range args {
+		name, option, ok := strings.Cut(strings.TrimSpace(arg), " ")
+		if !ok {
+			name, option, ok = strings.Cut(arg, "=")
+		}
+		if !ok || (name != "-o" && name != "--option") {
+			return nil, fmt.Errorf("invalid restic arg %q, only restic options (-o KEY=VALUE) are supported", arg)
+		}
+
+		option = strings.TrimSpace(option)
+		if key, _, ok := strings.Cut(option, "="); !ok || key == "" {
+			return nil, fmt.Errorf("invalid restic arg %q, the option must be KEY=VALUE", arg)
+		}
+		if strings.Contains(option, ",") {
+			return nil, fmt.Errorf("invalid restic arg %q, the option can't contain a comma", arg)
+		}
+
+		options = append(options, option)
+	}
+
+	return options, nil
+}
+
+// MaxRestoreWorkers is the upper limit of restore workers.
+const MaxRestoreWorkers = 64
+
+// backendConnectionsOption returns the restic option setting the number of backend connections of
+// the repository backend, or an empty string for unknown backends.
+func backendConnectionsOption(backend *k8upv1.Backend, connections int) string {
+	if backend == nil {
+		return ""
+	}
+
+	var scheme string
+	switch {
+	case backend.S3 != nil:
+		scheme = "s3"
+	case backend.B2 != nil:
+		scheme = "b2"
+	case backend.Azure != nil:
+		scheme = "azure"
+	case backend.GCS != nil:
+		scheme = "gs"
+	case backend.Swift != nil:
+		scheme = "swift"
+	case backend.Rest != nil:
+		scheme = "rest"
+	case backend.Local != nil:
+		scheme = "local"
+	default:
+		return ""
+	}
+
+	return fmt.Sprintf("%s.connections=%d", scheme, connections)
+}
+
+// restoreEnv returns the restic env of the restore job. The restic options and restore workers are
+// appended to RESTIC_OPTIONS, restore workers are set as the number of backend connections as restic
+// has no setting for restore workers.
+func (t *RestoreTask) restoreEnv(backend *k8upv1.Backend) []corev1.EnvVar {
+	env := append([]corev1.EnvVar{}, t.ResticEnv...)
+	if t.ResticCachePVC != "" {
+		env = append(env, corev1.EnvVar{Name: "RESTIC_CACHE_DIR", Value: resticCacheDir})
+	}
+
+	options := append([]string{}, t.ResticOptions...)
+	if t.RestoreWorkers > 0 {
+		if option := backendConnectionsOption(backend, t.RestoreWorkers); option != "" {
+			options = append(options, option)
+		} else {
+			log.Printf("Warning: unknown repository backend, ignoring restore workers")
+		}
+	}
+	if len(options) == 0 {
+		return env
+	}
+
+	for i := range env {
+		if env[i].Name == "RESTIC_OPTIONS" {
+			env[i].Value = strings.Join(append([]string{env[i].Value}, options...), ",")
+			return env
+		}
+	}
+
+	return append(env, corev1.EnvVar{Name: "RESTIC_OPTIONS", Value: strings.Join(options, ",")})
+}
+
+// resticOptionsEnv returns the value of the last RESTIC_OPTIONS env var, which is the one in effect.
+func resticOptionsEnv(env []corev1.EnvVar) (string, bool) {
+	value, ok := "", false
+	for _, e := range env {
+		if e.Name == "RESTIC_OPTIONS" {
+			value, ok = e.Value, true
+		}
+	}
+	return value, ok
+}
+
+// overriddenResticOptions returns the RESTIC_OPTIONS k8up added to the restore container after the
+// env of the PodConfig, and the options of the PodConfig they override. k8up appends the restic
+// options configured in the operator after the PodConfig env, so they take precedence.
+func overriddenResticOptions(container corev1.Container) (string, string, bool) {
+	var values []string
+	for _, e := range container.Env {
+		if e.Name == "RESTIC_OPTIONS" {
+			values = append(values, e.Value)
+		}
+	}
+	if len(values) < 2 || values[len(values)-1] == values[0] {
+		return "", "", false
+	}
+	return values[len(values)-1], values[0], true
+}
+
+// warnScheduleResticOptions warns when the backup PodConfig of the schedule sets RESTIC_OPTIONS.
+// The restore job does not use the PodConfig of the backups, so they don't apply to restores.
+func (t *RestoreTask) warnScheduleResticOptions(schedule k8upv1.Schedule) {
+	if schedule.Spec.Backup == nil || schedule.Spec.Backup.PodConfigRef == nil {
+		return
+	}
+
+	var podConfig k8upv1.PodConfig
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: schedule.Spec.Backup.PodConfigRef.Name}, &podConfig); err != nil {
+		return
+	}
+	for _, container := range podConfig.Spec.Template.Spec.Containers {
+		if options, ok := resticOptionsEnv(container.Env); ok {
+			log.Printf("Warning: backup pod config %s of the schedule sets RESTIC_OPTIONS=%s, restores don't use it, pass the options with -restic-arg", podConfig.Name, options)
+			return
+		}
+	}
+}
+
+// warnOperatorResticOptions warns when the RESTIC_OPTIONS configured in the k8up operator override
+// those of the restore PodConfig, once k8up created the restore job.
+func (t *RestoreTask) warnOperatorResticOptions(ctx context.Context, restore k8upv1.Restore) {
+	var job batchv1.Job
+	key := client.ObjectKey{Name: fmt.Sprintf("restore-%s", restore.Name)}
+	err := wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
+		return t.Client.Get(ctx, key, &job) == nil, nil
+	})
+	if err != nil || len(job.Spec.Template.Spec.Containers) == 0 {
+		return
+	}
+
+	if operator, options, ok := overriddenResticOptions(job.Spec.Template.Spec.Containers[0]); ok {
+		log.Printf("Warning: the k8up operator sets RESTIC_OPTIONS=%s, which overrides %s of -restore-workers, -restic-arg and -restic-env", operator, options)
+	}
+}
+
+// startWarningOperatorResticOptions checks the restic options of the restore job in the background.
+// The returned function stops waiting for the job.
+func (t *RestoreTask) startWarningOperatorResticOptions(restore k8upv1.Restore) func() {
+	ctx, cancel := context.WithCancel(t.Ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t.warnOperatorResticOptions(ctx, restore)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// needsPodConfig determines if the restore job needs a PodConfig to apply custom settings.
+func (t *RestoreTask) needsPodConfig() bool {
+	return len(t.ResticEnv) > 0 || len(t.ResticOptions) > 0 || t.RestoreWorkers > 0 || t.ResticCachePVC != "" || t.PriorityClass != ""
+}
+
+// createRestorePodConfig creates a k8up PodConfig which adds the custom restic env and priority
+// class to the restore job.
+func (t *RestoreTask) createRestorePodConfig(env []corev1.EnvVar) (*k8upv1.PodConfig, error) {
+	podConfig := k8upv1.PodConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        t.TaskKey,
+			Annotations: BackupExcludedAnnotations(),
+		},
+		Spec: k8upv1.PodConfigSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					PriorityClassName: t.PriorityClass,
+					// k8up overrides the name, image and command of the first container.
+					Containers: []corev1.Container{
+						{
+							Name: "restore",
+							Env:  env,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if len(env) == 0 {
+		podConfig.Spec.Template.Spec.Containers = nil
+	}
+
+	if err := t.Client.Create(t.Ctx, &podConfig); err != nil {
+		return nil, fmt.Errorf("failed to create pod config: %w", err)
+	}
+
+	return &podConfig, nil
+}
+
+// ValidatePriorityClass ensures the configured priority class exists.
+func (t *RestoreTask) ValidatePriorityClass() error {
+	if t.PriorityClass == "" {
+		return nil
+	}
+
+	var priorityClass schedulingv1.PriorityClass
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: t.PriorityClass}, &priorityClass); err != nil {
+		return fmt.Errorf("failed to get priority class %s: %w", t.PriorityClass, err)
+	}
+
+	return nil
+}
+
+// ownPodConfig makes the restore the owner of the PodConfig so it is garbage collected with it.
+func (t *RestoreTask) ownPodConfig(podConfig *k8upv1.PodConfig, restore *k8upv1.Restore) error {
+	if err := controllerutil.SetOwnerReference(restore, podConfig, t.Client.Scheme()); err != nil {
+		return err
+	}
+
+	return t.Client.Update(t.Ctx, podConfig)
+}
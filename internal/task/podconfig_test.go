@@ -0,0 +1,154 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"reflect"
+	"testing"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseResticArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "short option", args: []string{"-o s3.region=eu-central-1"}, want: []string{"s3.region=eu-central-1"}},
+		{name: "long option", args: []string{"--option s3.region=eu-central-1"}, want: []string{"s3.region=eu-central-1"}},
+		{name: "option with equals sign", args: []string{"--option=s3.storage-class=STANDARD"}, want: []string{"s3.storage-class=STANDARD"}},
+		{name: "repeated", args: []string{"-o=s3.region=eu-central-1", "-o s3.bucket-lookup=dns"}, want: []string{"s3.region=eu-central-1", "s3.bucket-lookup=dns"}},
+		{name: "other flag", args: []string{"--limit-download 1024"}, wantErr: true},
+		{name: "flag without value", args: []string{"--no-lock"}, wantErr: true},
+		{name: "option without value", args: []string{"-o s3.region"}, wantErr: true},
+		{name: "option with comma", args: []string{"-o s3.region=eu-central-1,s3.connections=2"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseResticArgs(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseResticArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseResticArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreEnv(t *testing.T) {
+	s3 := &k8upv1.Backend{S3: &k8upv1.S3Spec{}}
+
+	tests := []struct {
+		name    string
+		task    RestoreTask
+		backend *k8upv1.Backend
+		want    []corev1.EnvVar
+	}{
+		{name: "defaults", backend: s3},
+		{
+			name:    "restore workers",
+			task:    RestoreTask{RestoreWorkers: 8},
+			backend: s3,
+			want:    []corev1.EnvVar{{Name: "RESTIC_OPTIONS", Value: "s3.connections=8"}},
+		},
+		{
+			name:    "restore workers of unknown backend",
+			task:    RestoreTask{RestoreWorkers: 8},
+			backend: &k8upv1.Backend{},
+		},
+		{
+			name:    "restic options and restore workers",
+			task:    RestoreTask{ResticOptions: []string{"s3.region=eu-central-1"}, RestoreWorkers: 8},
+			backend: s3,
+			want:    []corev1.EnvVar{{Name: "RESTIC_OPTIONS", Value: "s3.region=eu-central-1,s3.connections=8"}},
+		},
+		{
+			name: "appended to restic env options",
+			task: RestoreTask{
+				ResticEnv:      []corev1.EnvVar{{Name: "RESTIC_OPTIONS", Value: "s3.bucket-lookup=dns"}},
+				ResticOptions:  []string{"s3.region=eu-central-1"},
+				RestoreWorkers: 8,
+			},
+			backend: s3,
+			want:    []corev1.EnvVar{{Name: "RESTIC_OPTIONS", Value: "s3.bucket-lookup=dns,s3.region=eu-central-1,s3.connections=8"}},
+		},
+		{
+			name:    "restic cache",
+			task:    RestoreTask{ResticCachePVC: "restic-cache"},
+			backend: s3,
+			want:    []corev1.EnvVar{{Name: "RESTIC_CACHE_DIR", Value: resticCacheDir}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.task.restoreEnv(tt.backend)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("restoreEnv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverriddenResticOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          []corev1.EnvVar
+		wantOperator string
+		wantOptions  string
+		wantOk       bool
+	}{
+		{name: "no options", env: []corev1.EnvVar{{Name: "RESTORE_DIR", Value: "/restore"}}},
+		{name: "pod config options only", env: []corev1.EnvVar{{Name: "RESTIC_OPTIONS", Value: "s3.connections=8"}}},
+		{name: "operator options only", env: []corev1.EnvVar{{Name: "RESTORE_DIR", Value: "/restore"}, {Name: "RESTIC_OPTIONS", Value: "s3.region=eu-central-1"}}},
+		{
+			name: "operator options override pod config",
+			env: []corev1.EnvVar{
+				{Name: "RESTIC_OPTIONS", Value: "s3.connections=8"},
+				{Name: "RESTORE_DIR", Value: "/restore"},
+				{Name: "RESTIC_OPTIONS", Value: "s3.region=eu-central-1"},
+			},
+			wantOperator: "s3.region=eu-central-1",
+			wantOptions:  "s3.connections=8",
+			wantOk:       true,
+		},
+		{
+			name: "same options",
+			env: []corev1.EnvVar{
+				{Name: "RESTIC_OPTIONS", Value: "s3.connections=8"},
+				{Name: "RESTIC_OPTIONS", Value: "s3.connections=8"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operator, options, ok := overriddenResticOptions(corev1.Container{Env: tt.env})
+			if operator != tt.wantOperator || options != tt.wantOptions || ok != tt.wantOk {
+				t.Errorf("overriddenResticOptions() = %q, %q, %v, want %q, %q, %v", operator, options, ok, tt.wantOperator, tt.wantOptions, tt.wantOk)
+			}
+		})
+	}
+}
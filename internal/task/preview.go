@@ -0,0 +1,87 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PreviewFile is a file of the snapshot which would be archived.
+type PreviewFile struct {
+	Path string
+	Size uint64
+}
+
+// snapshotNode is a node printed by `restic ls --json`.
+type snapshotNode struct {
+	StructType string `json:"struct_type"`
+	Type       string `json:"type"`
+	Path       string `json:"path"`
+	Size       uint64 `json:"size"`
+}
+
+// StartPreviewPod starts a pod listing the files of the snapshot matching the restore filter with
+// `restic ls --json --recursive`, read them with PreviewArchive.
+func (t *RestoreTask) StartPreviewPod(image string) (corev1.Pod, error) {
+	command := []string{"restic", "ls", "--json", "--recursive", "--no-lock", "--no-cache", t.Args.Snapshot()}
+	if t.Args.RestoreFilter != "" {
+		command = append(command, t.Args.RestoreFilter)
+	}
+
+	return t.startResticPod(fmt.Sprintf("preview-%s", t.TaskKey), image, command)
+}
+
+// PreviewArchive reads the listing of a finished preview pod and returns the files which would be
+// archived, applying the same hidden, path and extension filters as archiving. Sizes are the
+// uncompressed sizes in the snapshot.
+func (t *RestoreTask) PreviewArchive(pod corev1.Pod) ([]PreviewFile, error) {
+	stream, err := t.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{}).Stream(t.Ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get listing: %w", err)
+	}
+	defer stream.Close()
+
+	var files []PreviewFile
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var node snapshotNode
+		// restic prints warnings between the nodes, eg about the repository.
+		if err := json.Unmarshal(scanner.Bytes(), &node); err != nil {
+			continue
+		}
+		if node.StructType != "node" || node.Type != "file" {
+			continue
+		}
+
+		name := strings.TrimPrefix(node.Path, "/")
+		if (t.ExcludeHidden && t.isHidden(name)) || t.isExcludedPath(name) || t.isExcludedExt(name, false) {
+			continue
+		}
+		files = append(files, PreviewFile{Path: node.Path, Size: node.Size})
+	}
+	if err := scanner.Err(); err != nil {
+		return files, fmt.Errorf("failed to read listing: %w", err)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// managedPVCAnnotations are annotations of PVCs set by the task which can't be overridden.
+var managedPVCAnnotations = map[string]bool{
+	backupAnnotation:      true,
+	snapshotAnnotation:    true,
+	filterAnnotation:      true,
+	archiveLockAnnotation: true,
+}
+
+// ParsePVCAnnotations parses KEY=VALUE pairs into annotations of the restore and archive PVCs, eg
+// to attribute their storage cost to a project. Values are templates like the restore target, eg
+// `{{env "LAGOON_PROJECT"}}`.
+func ParsePVCAnnotations(pairs []string, data TargetData) (map[string]string, error) {
+	annotations := map[string]string{}
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid pvc annotation %q, must be KEY=VALUE", pair)
+		}
+
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid pvc annotation %s: %s", key, strings.Join(errs, ", "))
+		}
+
+		if managedPVCAnnotations[key] {
+			return nil, fmt.Errorf("invalid pvc annotation %s, it is managed by the restore task", key)
+		}
+
+		rendered, err := renderTemplate("annotation", value, data)
+		if err != nil {
+			return nil, err
+		}
+		annotations[key] = rendered
+	}
+
+	return annotations, nil
+}
+
+// CheckRequiredPVCAnnotations ensures the annotations required by the cluster policy are set and not
+// empty, eg because the env var of a template is not set.
+func CheckRequiredPVCAnnotations(annotations map[string]string, required []string) error {
+	var missing []string
+	for _, key := range required {
+		if annotations[key] == "" {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("required pvc annotations are missing or empty: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// permission is an RBAC verb on a resource required by the task. Cluster scoped resources are
+// reviewed without a namespace.
+type permission struct {
+	Verb        string
+	Group       string
+	Resource    string
+	Subresource string
+	Cluster     bool
+}
+
+func (p permission) String() string {
+	resource := p.Resource
+	if p.Subresource != "" {
+		resource += "/" + p.Subresource
+	}
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	return fmt.Sprintf("%s %s", p.Verb, resource)
+}
+
+// requiredPermissions are the permissions the restore subcommand needs in the task namespace with
+// any flags.
+var requiredPermissions = []permission{
+	{Verb: "get", Group: "k8up.io", Resource: "schedules"},
+	{Verb: "list", Group: "k8up.io", Resource: "snapshots"},
+	{Verb: "list", Group: "k8up.io", Resource: "backups"},
+	{Verb: "create", Group: "k8up.io", Resource: "restores"},
+	{Verb: "get", Group: "k8up.io", Resource: "restores"},
+	{Verb: "watch", Group: "k8up.io", Resource: "restores"},
+	{Verb: "delete", Group: "k8up.io", Resource: "restores"},
+	{Verb: "create", Resource: "persistentvolumeclaims"},
+	{Verb: "get", Resource: "persistentvolumeclaims"},
+	{Verb: "delete", Resource: "persistentvolumeclaims"},
+	{Verb: "create", Resource: "pods"},
+	{Verb: "get", Resource: "pods"},
+	{Verb: "list", Resource: "pods"},
+	{Verb: "watch", Resource: "pods"},
+	{Verb: "delete", Resource: "pods"},
+	{Verb: "get", Resource: "pods", Subresource: "log"},
+	{Verb: "get", Resource: "namespaces", Cluster: true},
+	{Verb: "get", Resource: "persistentvolumes", Cluster: true},
+}
+
+// PermissionFeatures are the features enabled by flags of the restore subcommand which need
+// further permissions and aren't part of the task config.
+type PermissionFeatures struct {
+	RestoreNamespace       bool
+	CreateRestoreNamespace bool
+	InPlace                bool
+	ScaleDown              bool
+}
+
+// requiredPermissions returns the permissions the task needs with the enabled features.
+func (t *RestoreTask) requiredPermissions(features PermissionFeatures) []permission {
+	required := append([]permission{}, requiredPermissions...)
+	add := func(p ...permission) {
+		required = append(required, p...)
+	}
+
+	if t.StorageClass != "" {
+		add(permission{Verb: "get", Group: "storage.k8s.io", Resource: "storageclasses", Cluster: true},
+			permission{Verb: "list", Group: "storage.k8s.io", Resource: "storageclasses", Cluster: true})
+	}
+	if t.needsPodConfig() {
+		add(permission{Verb: "create", Group: "k8up.io", Resource: "podconfigs"},
+			permission{Verb: "update", Group: "k8up.io", Resource: "podconfigs"},
+			permission{Verb: "delete", Group: "k8up.io", Resource: "podconfigs"})
+	}
+	if t.PriorityClass != "" {
+		add(permission{Verb: "get", Group: "scheduling.k8s.io", Resource: "priorityclasses", Cluster: true})
+	}
+	if t.RestoreBackoffLimit != nil {
+		add(permission{Verb: "get", Group: "batch", Resource: "jobs"},
+			permission{Verb: "patch", Group: "batch", Resource: "jobs"})
+	}
+	if t.Force {
+		add(permission{Verb: "patch", Resource: "persistentvolumeclaims"})
+	}
+	if t.ArchivePVC != "" {
+		add(permission{Verb: "update", Resource: "persistentvolumeclaims"})
+	}
+	if t.ReclaimRetainedPVs {
+		add(permission{Verb: "patch", Resource: "persistentvolumes", Cluster: true})
+	}
+	if features.RestoreNamespace {
+		add(permission{Verb: "get", Resource: "secrets"},
+			permission{Verb: "create", Resource: "secrets"},
+			permission{Verb: "delete", Resource: "secrets"})
+	}
+	if features.CreateRestoreNamespace {
+		add(permission{Verb: "create", Resource: "namespaces", Cluster: true},
+			permission{Verb: "delete", Resource: "namespaces", Cluster: true})
+	}
+	if features.InPlace {
+		add(permission{Verb: "get", Group: "apps", Resource: "deployments"})
+	}
+	if features.ScaleDown {
+		add(permission{Verb: "update", Group: "apps", Resource: "deployments"})
+	}
+
+	return required
+}
+
+// MissingPermissions checks the permissions of the task's service account for the enabled features
+// with SelfSubjectAccessReviews and returns any that are not allowed.
+func (t *RestoreTask) MissingPermissions(features PermissionFeatures) ([]string, error) {
+	var missing []string
+	for _, p := range t.requiredPermissions(features) {
+		namespace := t.Namespace
+		if p.Cluster {
+			namespace = ""
+		}
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   namespace,
+					Verb:        p.Verb,
+					Group:       p.Group,
+					Resource:    p.Resource,
+					Subresource: p.Subresource,
+				},
+			},
+		}
+
+		result, err := t.Clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(t.Ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to review access to %s: %w", p, err)
+		}
+
+		if !result.Status.Allowed {
+			missing = append(missing, p.String())
+		}
+	}
+
+	return missing, nil
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+)
+
+// ValidateRepositoryPath checks a repository path override is a clean relative path.
+func ValidateRepositoryPath(repoPath string) error {
+	if repoPath == "" {
+		return nil
+	}
+	if path.IsAbs(repoPath) || path.Clean(repoPath) != repoPath || strings.HasPrefix(repoPath, "..") {
+		return fmt.Errorf("repository path %q must be a clean relative path", repoPath)
+	}
+	return nil
+}
+
+// restoreBackend returns the backend of the restore, with the repository path within the bucket
+// replaced when overridden. The schedule credentials are used, so the repository must be in the
+// same bucket, the global bucket when the schedule has none.
+func (t *RestoreTask) restoreBackend(backend *k8upv1.Backend) (*k8upv1.Backend, error) {
+	if t.RepositoryPath == "" {
+		return backend, nil
+	}
+
+	if backend == nil || backend.S3 == nil {
+		return nil, fmt.Errorf("repository path can only be overridden for S3 backends")
+	}
+
+	bucket, _, _ := strings.Cut(backend.S3.Bucket, "/")
+	if bucket == "" {
+		bucket, _, _ = strings.Cut(t.GlobalS3Bucket, "/")
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("schedule backend has no S3 bucket, k8up uses its global bucket which the task can't read, pass -global-s3-bucket")
+	}
+
+	override := backend.DeepCopy()
+	override.S3.Bucket = path.Join(bucket, t.RepositoryPath)
+
+	return override, nil
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"io/fs"
+	"sort"
+	"time"
+
+	"github.com/mholt/archives"
+)
+
+// reproducibleModTime is the modification time of all files in reproducible archives.
+var reproducibleModTime = time.Unix(0, 0)
+
+// reproducibleFileInfo hides the modification time and owner of a file, so archives only depend on
+// the file names, modes and contents.
+type reproducibleFileInfo struct {
+	fs.FileInfo
+}
+
+func (fi reproducibleFileInfo) ModTime() time.Time {
+	return reproducibleModTime
+}
+
+// Sys hides the stat of the file, which the tar header uses for the owner and access times.
+func (fi reproducibleFileInfo) Sys() any {
+	return nil
+}
+
+// reproducibleFiles sorts the files by name and normalizes their metadata. The gzip header has no
+// timestamp or name since the archive writer does not set them.
+func reproducibleFiles(files []archives.FileInfo) []archives.FileInfo {
+	normalized := make([]archives.FileInfo, len(files))
+	for i, file := range files {
+		file.FileInfo = reproducibleFileInfo{file.FileInfo}
+		normalized[i] = file
+	}
+
+	sort.SliceStable(normalized, func(i, j int) bool {
+		return normalized[i].NameInArchive < normalized[j].NameInArchive
+	})
+
+	return normalized
+}
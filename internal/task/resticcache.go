@@ -0,0 +1,78 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// resticCacheVolume is the name of the restic cache volume in the restore job.
+	resticCacheVolume = "restic-cache"
+	// resticCacheDir is where the restic cache PVC is mounted in the restore job.
+	resticCacheDir = "/restic-cache"
+)
+
+// ValidateResticCachePVC ensures the PVC keeping the restic cache between restores exists.
+func (t *RestoreTask) ValidateResticCachePVC() error {
+	if t.ResticCachePVC == "" {
+		return nil
+	}
+
+	var pvc corev1.PersistentVolumeClaim
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: t.ResticCachePVC}, &pvc); err != nil {
+		return fmt.Errorf("failed to get restic cache pvc %s: %w", t.ResticCachePVC, err)
+	}
+
+	return nil
+}
+
+// addResticCache mounts the restic cache PVC into the restore job. restic keeps the repository
+// index, snapshots and tree packs in its cache, so restores from the same repository don't fetch
+// them from the backend again. The cache dir is set with RESTIC_CACHE_DIR in restoreEnv.
+func (t *RestoreTask) addResticCache(restore *k8upv1.Restore) {
+	if t.ResticCachePVC == "" {
+		return
+	}
+
+	volumes := []k8upv1.RunnableVolumeSpec{
+		{
+			Name: resticCacheVolume,
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: t.ResticCachePVC,
+			},
+		},
+	}
+	restore.Spec.RunnableSpec.Volumes = &volumes
+
+	// k8up mounts the volume mounts of the restore method, meant for TLS certificates, into the
+	// restore container.
+	mounts := []corev1.VolumeMount{
+		{
+			Name:      resticCacheVolume,
+			MountPath: resticCacheDir,
+		},
+	}
+	if restore.Spec.RestoreMethod.VolumeMounts != nil {
+		mounts = append(*restore.Spec.RestoreMethod.VolumeMounts, mounts...)
+	}
+	restore.Spec.RestoreMethod.VolumeMounts = &mounts
+}
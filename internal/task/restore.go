@@ -18,26 +18,31 @@ package task
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"math/rand/v2"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
+	"time"
 
 	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
 	"github.com/mholt/archives"
 	"github.com/uselagoon/machinery/api/lagoon"
 	lclient "github.com/uselagoon/machinery/api/lagoon/client"
+	"github.com/uselagoon/machinery/api/schema"
 	"github.com/uselagoon/machinery/utils/sshtoken"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
 	k8runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -51,9 +56,53 @@ var (
 	GoVersion   = runtime.Version()
 )
 
+// Behaviours when the restore filter matched no files.
+const (
+	OnEmptyFail       = "fail"
+	OnEmptyWarn       = "warn"
+	OnEmptySkipUpload = "skip-upload"
+)
+
+// DefaultResourcePrefix is the default prefix of the names of resources created by the task.
+const DefaultResourcePrefix = "rft"
+
+// maxResourcePrefix leaves room in the restore job name, limited to 63 characters, for the task ID.
+const maxResourcePrefix = 20
+
+// DefaultSSHKeyPath is where the upload pod mounts the Lagoon SSH key.
+const DefaultSSHKeyPath = "/var/run/secrets/lagoon/ssh/ssh-privatekey"
+
+// DefaultFailFastReasons are the restore condition reasons treated as terminal with fail fast, in
+// addition to CreationFailed which always ends the wait.
+var DefaultFailFastReasons = []string{
+	string(k8upv1.ReasonUpdateFailed),
+	string(k8upv1.ReasonDeletionFailed),
+	string(k8upv1.ReasonRetrievalFailed),
+}
+
+// infoFileName is the name of the file describing the restore in the archive root.
+const infoFileName = "RESTORE_INFO.txt"
+
+// ErrEmptyRestore is returned when there are no restored files to archive.
+var ErrEmptyRestore = errors.New("restore filter matched no files")
+
 type TaskArgs struct {
 	BackupId      string `json:"backup_id"`
 	RestoreFilter string `json:"restore_path"`
+	Description   string `json:"description,omitempty"`
+	// SnapshotId is the full snapshot ID resolved from BackupId.
+	SnapshotId string `json:"snapshot_id,omitempty"`
+	// MergeBackupIds are snapshots restored on top of BackupId into the same restore, in order, so
+	// files of later snapshots overwrite files of earlier ones.
+	MergeBackupIds []string `json:"merge_backup_ids,omitempty"`
+}
+
+// Snapshot returns the resolved snapshot ID, falling back to the requested backup ID.
+func (a TaskArgs) Snapshot() string {
+	if a.SnapshotId != "" {
+		return a.SnapshotId
+	}
+	return a.BackupId
 }
 
 type RestoreTask struct {
@@ -66,11 +115,199 @@ type RestoreTask struct {
 	Client         client.Client
 	WatchingClient client.WithWatch
 	Clientset      kubernetes.Clientset
-	TaskId         string
-	TaskKey        string
-	TokenHost      string
-	TokenPort      string
-	APIHost        string
+	Namespace      string
+	// ClusterClient is not bound to a namespace, to manage restore namespaces.
+	ClusterClient client.Client
+	// SourceClient reads the backups, snapshots and schedule of the environment in SourceNamespace,
+	// which differs from Namespace when restoring into a restore namespace.
+	SourceClient    client.Client
+	SourceNamespace string
+	TaskId          string
+	TaskKey         string
+	TokenHost       string
+	TokenPort       string
+	APIHost         string
+	SSHKeyPath      string
+
+	// OnEmpty is the behaviour when the restore is empty, one of the OnEmpty constants.
+	OnEmpty string
+	// ResticEnv are additional env vars for restic in the restore job.
+	ResticEnv []corev1.EnvVar
+	// ResticOptions are additional restic options (KEY=VALUE) of the restore job.
+	ResticOptions []string
+	// RestoreWorkers is the number of backend connections of the restore job, 0 keeps the default.
+	RestoreWorkers int
+	// PVCAnnotations are custom annotations of the restore and archive PVCs, eg for cost tracking.
+	PVCAnnotations map[string]string
+	// ResticCachePVC is an existing PVC keeping the restic cache of the restore job between
+	// restores.
+	ResticCachePVC string
+	// NoInfoFile disables adding RESTORE_INFO.txt to the archive.
+	NoInfoFile bool
+	// LookupBackoff is the retry backoff of the initial resource lookups.
+	LookupBackoff wait.Backoff
+	// CleanupBackoff is the retry backoff of removing the resources of the task.
+	CleanupBackoff wait.Backoff
+	// ReclaimRetainedPVs sets the reclaim policy of PVs of the task with the Retain policy to Delete
+	// before removing their PVCs, so their storage is released.
+	ReclaimRetainedPVs bool
+	// PVCSize is the requested size of the created PVCs.
+	PVCSize string
+	// StorageClass is the storage class of the created PVCs.
+	StorageClass string
+	// VolumeMode is the volume mode of the created PVCs.
+	VolumeMode corev1.PersistentVolumeMode
+	// PriorityClass is the priority class of the restore job and upload pod.
+	PriorityClass string
+	// OutputFormat is the format of the restored files, one of the OutputFormat constants.
+	OutputFormat string
+	// IntegrityCheck verifies restored files against checksum manifests before archiving.
+	IntegrityCheck bool
+	// IntegrityCheckPercent is the percentage of files listed in checksum manifests verified by the
+	// integrity check.
+	IntegrityCheckPercent int
+	// VerifySampleFiles or VerifySamplePercent is the number or percentage of restored files read
+	// back to verify the restore, zero for neither.
+	VerifySampleFiles   int
+	VerifySamplePercent int
+	// VerifyArchive reads the archive back before uploading it to catch corrupt archives.
+	VerifyArchive bool
+	// DiffPVC is the live PVC mounted read-only in the upload pod to diff the restore against.
+	DiffPVC string
+	// DiffTarget is the path of the live files to diff the restore against.
+	DiffTarget string
+	// DiffSnapshot is the snapshot restored to diff the restore against, the diff report is uploaded
+	// with the restore. With DiffOnly only the diff report is uploaded.
+	DiffSnapshot string
+	DiffOnly     bool
+	// ArchiveMode is the file mode of the archive, the default umask based mode is used if unset.
+	ArchiveMode os.FileMode
+	// ArchiveUID and ArchiveGID are the owner of the archive, -1 keeps the current owner.
+	ArchiveUID int
+	ArchiveGID int
+	// RestoreMethods are the restore methods to try in order, falling back to the next one when the
+	// restore destination can't be created.
+	RestoreMethods []string
+	// S3Restore is the destination of S3 restores.
+	S3Restore *k8upv1.S3Spec
+	// Reproducible creates byte identical archives for the same restored files.
+	Reproducible bool
+	// UploadTimeout limits the upload of the archive to the Lagoon tasks, unlimited when zero.
+	UploadTimeout time.Duration
+	// RestoreTimeout limits waiting for the restore, eg when k8up never sets a terminal condition,
+	// unlimited when zero.
+	RestoreTimeout time.Duration
+	// UploadPodTimeout limits waiting for the upload pod and the pods running restic, unlimited when
+	// zero.
+	UploadPodTimeout time.Duration
+	// ArchivePVC is a shared archive PVC reused by every run instead of creating one per run.
+	ArchivePVC string
+	// KeepArchiveOnFailure keeps the archive PVC when the upload fails.
+	KeepArchiveOnFailure bool
+	// OnClosedTask is the behaviour when the Lagoon task no longer accepts uploads, one of the
+	// OnClosedTask constants.
+	OnClosedTask string
+	// RepositoryPath overrides the path of the restic repository within the S3 bucket.
+	RepositoryPath string
+	// GlobalS3Endpoint and GlobalS3Bucket are the global S3 settings of the k8up operator, and
+	// GlobalS3Secret the secret holding its global credentials, for the pods running restic when the
+	// schedule backend leaves them unset.
+	GlobalS3Endpoint string
+	GlobalS3Bucket   string
+	GlobalS3Secret   string
+	// SnapshotBefore and SnapshotAfter limit the snapshots resolved by ID or `latest` to a time
+	// window, unset when zero.
+	SnapshotBefore time.Time
+	SnapshotAfter  time.Time
+	// AllowHostMismatch restores a latest snapshot taken under another restic host than the
+	// environment's.
+	AllowHostMismatch bool
+	// Symlinks is the handling of symlinks when archiving, one of the Symlinks constants.
+	Symlinks string
+	// EncryptRecipients are the age recipients the archive is encrypted for.
+	EncryptRecipients []string
+	// Strict fails archiving restores with special files instead of skipping them.
+	Strict bool
+	// Resume keeps the restore PVC of a failed restore, and restores into it again instead of a new
+	// PVC, skipping files that were already restored.
+	Resume bool
+	// RestoreBackoffLimit is the number of retries of the restore job before it fails, nil keeps
+	// the Kubernetes default.
+	RestoreBackoffLimit *int32
+	// ScaleDownTimeout is how long to wait for the pods of a deployment scaled down for an in-place
+	// restore to stop.
+	ScaleDownTimeout time.Duration
+	// PVCTerminationTimeout is how long to wait for a terminating PVC of a previous run with the
+	// same name, 0 does not wait.
+	PVCTerminationTimeout time.Duration
+	// Force removes the finalizers of a PVC still terminating after PVCTerminationTimeout.
+	Force bool
+	// AdditionalTaskIds are Lagoon tasks the archive is uploaded to in addition to the task.
+	AdditionalTaskIds []string
+	// ArchiveFormat is the format of the archive, one of the ArchiveFormat constants, tar.gz when
+	// unset.
+	ArchiveFormat string
+	// Rsyncable compresses the archive with rsyncable gzip framing.
+	Rsyncable bool
+	// CompressionLevel is the gzip level of the archive, the gzip default when zero.
+	CompressionLevel int
+	// CompressionThreads is the number of blocks of CompressionBlockSize compressed in parallel.
+	CompressionThreads   int
+	CompressionBlockSize int
+	// CaseCollisions is the handling of paths differing only in case, one of the CaseCollisions
+	// constants.
+	CaseCollisions string
+	// LogSections marks the phases of the task in the log, see Section.
+	LogSections bool
+	// LogFormat is the format of the log, one of the LogFormat constants, set by SetupLogging.
+	LogFormat string
+	// SparseFiles is the handling of large sparse files, one of the SparseFiles constants.
+	SparseFiles string
+	// UploadFiles is the pattern of files uploaded individually with the files output format, at
+	// most MaxUploadFiles.
+	UploadFiles    string
+	MaxUploadFiles int
+	// UploadAtomicity is the handling of files uploaded before another upload failed, one of the
+	// UploadAtomicity constants.
+	UploadAtomicity string
+	// ExcludePaths are patterns of paths left out of the archive.
+	ExcludePaths []string
+	// IncludeExtensions limits the archive to files with these extensions, ExcludeExtensions
+	// leaves files with these extensions out of it.
+	IncludeExtensions []string
+	ExcludeExtensions []string
+	// ExcludeHidden leaves dotfiles and dot directories out of the archive.
+	ExcludeHidden bool
+	// ExpectedFiles is the number of files in the snapshot matching the restore filter, to check
+	// the restore is complete. It is -1 when not checked.
+	ExpectedFiles int
+	// FileCountTolerance is the percentage the restored file count may differ from ExpectedFiles.
+	FileCountTolerance int
+
+	// createdNamespace is set when the task created the restore namespace.
+	createdNamespace bool
+	// copiedSecrets are the secrets copied into the restore namespace.
+	copiedSecrets []string
+
+	// ResourcePrefix is the prefix of the task key, which names all resources of the task.
+	ResourcePrefix string
+
+	// ListFiles is the number of restored files logged before archiving.
+	ListFiles int
+	// FailFastReasons are condition reasons that end the wait for the restore early.
+	FailFastReasons []string
+	// FollowLogs streams the restore job logs while waiting for the restore.
+	FollowLogs bool
+	// WaitMode is how the task waits for the restore and upload, watch or poll.
+	WaitMode string
+	// PollInterval is the interval between gets when polling the restore and upload.
+	PollInterval time.Duration
+	// LogConcurrency is the number of pod logs streamed at the same time.
+	LogConcurrency int
+
+	// spanCtx carries the span of the current phase. It is kept apart from Ctx, which goroutines
+	// read while spans start and end, and is only used by the span methods.
+	spanCtx context.Context
 }
 
 func NewRestoreTask(
@@ -82,6 +319,7 @@ func NewRestoreTask(
 	tokenHost string,
 	tokenPort string,
 	apiHost string,
+	resourcePrefix string,
 ) (*RestoreTask, error) {
 	// Create a schema with k8up resources.
 	var clientScheme = k8runtime.NewScheme()
@@ -113,76 +351,155 @@ func NewRestoreTask(
 			BackupId:      backupId,
 			RestoreFilter: restoreFilter,
 		},
-		Client:         namespaceClient,
-		WatchingClient: clientWithWatch,
-		Clientset:      *clientSet,
-		TaskId:         taskId,
-		TaskKey:        fmt.Sprintf("rft-%s", taskId),
-		TokenHost:      tokenHost,
-		TokenPort:      tokenPort,
-		APIHost:        apiHost,
-		Ctx:            context.TODO(),
+		Client:                namespaceClient,
+		WatchingClient:        clientWithWatch,
+		Clientset:             *clientSet,
+		Namespace:             namespace,
+		ClusterClient:         controllerClient,
+		SourceClient:          namespaceClient,
+		SourceNamespace:       namespace,
+		TaskId:                taskId,
+		ResourcePrefix:        resourcePrefix,
+		TaskKey:               fmt.Sprintf("%s-%s", resourcePrefix, taskId),
+		TokenHost:             tokenHost,
+		TokenPort:             tokenPort,
+		APIHost:               apiHost,
+		SSHKeyPath:            DefaultSSHKeyPath,
+		OnEmpty:               OnEmptyFail,
+		OutputFormat:          OutputFormatArchive,
+		VolumeMode:            corev1.PersistentVolumeFilesystem,
+		StorageClass:          DefaultStorageClass,
+		PVCSize:               DefaultPVCSize,
+		LookupBackoff:         DefaultLookupBackoff,
+		CleanupBackoff:        DefaultCleanupBackoff,
+		RestoreMethods:        []string{RestoreMethodFolder},
+		IntegrityCheckPercent: 100,
+		LogConcurrency:        DefaultLogConcurrency,
+		WaitMode:              WaitModeWatch,
+		PollInterval:          DefaultPollInterval,
+		Symlinks:              SymlinksPreserve,
+		ArchiveUID:            -1,
+		ExpectedFiles:         -1,
+		ArchiveGID:            -1,
+		Ctx:                   context.TODO(),
 	}, nil
 }
 
 // CreateRestorePVC creates a PVC to attach to a k8up Restore.
 func (t *RestoreTask) CreateRestorePVC(name string, size string) (corev1.PersistentVolumeClaim, error) {
-	storageClassName := "bulk"
 	pvc := corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: name,
-			Annotations: map[string]string{
-				"k8up.io/backup": "false", // Ensure backups skip this PVC.
-			},
+			Name:        name,
+			Annotations: t.restorePVCAnnotations(),
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
 			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
-			StorageClassName: &storageClassName,
+			StorageClassName: t.storageClassName(),
+			VolumeMode:       &t.VolumeMode,
 			Resources: corev1.VolumeResourceRequirements{
 				Requests: corev1.ResourceList{
-					// When bulk storage is backed by NFS, the size doesn't matter.
-					// There is no way to know ahead of time how large the restored files will be.
+					// When bulk storage is backed by NFS, the size doesn't matter. Block storage
+					// needs PVCSize to fit the restored files, there is no way to know ahead of time
+					// how large they will be.
 					corev1.ResourceStorage: resource.MustParse(size),
 				},
 			},
 		},
 	}
 
+	if err := t.waitForTerminatingPVC(name); err != nil {
+		return corev1.PersistentVolumeClaim{}, err
+	}
+
 	err := t.Client.Create(t.Ctx, &pvc)
 	if err != nil {
+		if isQuotaExceeded(err) {
+			return corev1.PersistentVolumeClaim{}, fmt.Errorf("storage quota exceeded in namespace %s — cannot provision restore PVC %s of %s: %w", t.Namespace, name, size, err)
+		}
 		return corev1.PersistentVolumeClaim{}, err
 	}
 
 	return pvc, nil
 }
 
+// DefaultPVCSize is the requested size of the restore and archive PVCs.
+const DefaultPVCSize = "1Gi"
+
+// ValidatePVCSize ensures a PVC size is a positive quantity.
+func ValidatePVCSize(size string) error {
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return fmt.Errorf("invalid pvc size %q: %w", size, err)
+	}
+	if quantity.Sign() <= 0 {
+		return fmt.Errorf("invalid pvc size %s, must be positive", size)
+	}
+	return nil
+}
+
+// ValidateVolumeMode ensures a volume mode can be used for the restore. k8up folder restores and the
+// archive step both write files, so only Filesystem volumes are supported.
+func ValidateVolumeMode(mode corev1.PersistentVolumeMode) error {
+	switch mode {
+	case corev1.PersistentVolumeFilesystem:
+		return nil
+	case corev1.PersistentVolumeBlock:
+		return fmt.Errorf("volume mode Block is not supported: the folder restore method and archiving need a filesystem")
+	default:
+		return fmt.Errorf("unknown volume mode %q, must be Filesystem", mode)
+	}
+}
+
+// ValidateResourcePrefix checks the resource prefix can be used in the names of all resources,
+// including the restore job k8up names after the restore.
+func ValidateResourcePrefix(prefix string) error {
+	if errs := validation.IsDNS1123Label(prefix); len(errs) > 0 {
+		return fmt.Errorf("invalid resource prefix %q: %s", prefix, strings.Join(errs, ", "))
+	}
+	if len(prefix) > maxResourcePrefix {
+		return fmt.Errorf("resource prefix %q is longer than %d characters", prefix, maxResourcePrefix)
+	}
+	return nil
+}
+
+// isQuotaExceeded determines if an API error was caused by a ResourceQuota rejecting the request.
+func isQuotaExceeded(err error) bool {
+	return apierrors.IsForbidden(err) && strings.Contains(err.Error(), "exceeded quota")
+}
+
 // StartRestore creates a k8up Restore resource to start restoring files from a backup.
 func (t *RestoreTask) StartRestore(pvc corev1.PersistentVolumeClaim) (k8upv1.Restore, error) {
+	return t.startRestore(folderRestoreMethod(pvc))
+}
+
+func (t *RestoreTask) startRestore(method *k8upv1.RestoreMethod) (k8upv1.Restore, error) {
 	// Load the Schedule resource to get restic config.
-	var schedule k8upv1.Schedule
-	if err := t.Client.Get(t.Ctx, client.ObjectKey{
-		Name: "k8up-lagoon-backup-schedule",
-	}, &schedule); err != nil {
+	schedule, err := t.GetSchedule()
+	if err != nil {
 		return k8upv1.Restore{}, fmt.Errorf("failed to get schedule: %w", err)
 	}
 
+	backend, err := t.restoreBackend(schedule.Spec.Backend)
+	if err != nil {
+		return k8upv1.Restore{}, err
+	}
+	if backend != schedule.Spec.Backend {
+		log.Printf("Restoring from repository %s", backend.S3.Bucket)
+	}
+	t.warnScheduleResticOptions(schedule)
+
 	failedJobsHistoryLimit := 1
 	newRestore := k8upv1.Restore{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: t.TaskKey,
+			Name:        t.TaskKey,
+			Annotations: BackupExcludedAnnotations(),
 		},
 		Spec: k8upv1.RestoreSpec{
-			Snapshot:      t.Args.BackupId,
+			Snapshot:      t.Args.Snapshot(),
 			RestoreFilter: t.Args.RestoreFilter,
-			RestoreMethod: &k8upv1.RestoreMethod{
-				Folder: &k8upv1.FolderRestore{
-					PersistentVolumeClaimVolumeSource: &corev1.PersistentVolumeClaimVolumeSource{
-						ClaimName: pvc.Name,
-					},
-				},
-			},
+			RestoreMethod: method,
 			RunnableSpec: k8upv1.RunnableSpec{
-				Backend: schedule.Spec.Backend,
+				Backend: backend,
 			},
 			KeepJobs:               &failedJobsHistoryLimit,
 			FailedJobsHistoryLimit: &failedJobsHistoryLimit,
@@ -194,25 +511,67 @@ func (t *RestoreTask) StartRestore(pvc corev1.PersistentVolumeClaim) (k8upv1.Res
 		newRestore.Spec.RunnableSpec.PodSecurityContext = schedule.Spec.PodSecurityContext
 	}
 
-	err := t.Client.Create(t.Ctx, &newRestore)
+	t.addResticCache(&newRestore)
+
+	env := t.restoreEnv(backend)
+	t.logRestoreParameters(backend, method, env)
+
+	// Custom restic env and priority class can only be added to the restore job with a PodConfig.
+	var podConfig *k8upv1.PodConfig
+	if t.needsPodConfig() {
+		var err error
+		podConfig, err = t.createRestorePodConfig(env)
+		if err != nil {
+			return k8upv1.Restore{}, err
+		}
+		newRestore.Spec.RunnableSpec.PodConfigRef = &corev1.LocalObjectReference{Name: podConfig.Name}
+	}
+
+	err = t.Client.Create(t.Ctx, &newRestore)
 	if err != nil {
+		if podConfig != nil {
+			if err := t.Client.Delete(t.Ctx, podConfig); err != nil {
+				log.Printf("Failed to clean up pod config: %v", err)
+			}
+		}
 		return k8upv1.Restore{}, fmt.Errorf("failed to create restore: %w", err)
 	}
 
+	if podConfig != nil {
+		if err := t.ownPodConfig(podConfig, &newRestore); err != nil {
+			log.Printf("Failed to set owner of pod config %s, it must be removed manually: %v", podConfig.Name, err)
+		}
+	}
+
 	return newRestore, nil
 }
 
 // WaitForRestore waits for the Restore to complete or timeout.
 func (t *RestoreTask) WaitForRestore(restore k8upv1.Restore) error {
-	w, err := t.WatchingClient.Watch(t.Ctx, &k8upv1.RestoreList{}, &client.ListOptions{
-		Namespace:     restore.Namespace,
-		FieldSelector: fields.OneTermEqualSelector("metadata.name", restore.Name),
-	})
+	w, err := t.watchObject(&restore, &k8upv1.RestoreList{})
 	if err != nil {
 		return fmt.Errorf("failed to watch restore: %w", err)
 	}
 	defer w.Stop()
 
+	ctx, cancel := t.stopAfter(w, "restore "+restore.Name, t.RestoreTimeout)
+	defer cancel()
+
+	if t.FollowLogs {
+		stopFollowing := t.startFollowingRestoreLogs(restore)
+		defer stopFollowing()
+	}
+
+	if t.RestoreBackoffLimit != nil {
+		stopSetting := t.startSettingRestoreBackoffLimit(restore)
+		defer stopSetting()
+	}
+
+	if restore.Spec.PodConfigRef != nil {
+		stopWarning := t.startWarningOperatorResticOptions(restore)
+		defer stopWarning()
+	}
+
 	for event := range w.ResultChan() {
 		restoreWatch, ok := event.Object.(*k8upv1.Restore)
 		if !ok {
@@ -222,15 +581,20 @@ func (t *RestoreTask) WaitForRestore(restore k8upv1.Restore) error {
 
 		ready := meta.FindStatusCondition(restoreWatch.Status.Conditions, "Ready")
 		if ready != nil {
-			log.Printf("Restore progress: %s\n", ready.Message)
+			t.logRestoreProgress(restore.Name, ready)
 			if ready.Reason == "CreationFailed" {
 				break
 			}
 		}
 
+		if condition := t.failFastCondition(restoreWatch.Status.Conditions); condition != nil {
+			log.Printf("Restore condition %s is %s, failing fast: %s\n", condition.Type, condition.Reason, condition.Message)
+			break
+		}
+
 		progressing := meta.FindStatusCondition(restoreWatch.Status.Conditions, "Progressing")
 		if progressing != nil && progressing.Status == metav1.ConditionTrue {
-			log.Printf("Restore progress: %s\n", progressing.Message)
+			t.logRestoreProgress(restore.Name, progressing)
 		}
 
 		completed := meta.FindStatusCondition(restoreWatch.Status.Conditions, "Completed")
@@ -241,11 +605,30 @@ func (t *RestoreTask) WaitForRestore(restore k8upv1.Restore) error {
 
 	w.Stop()
 
+	if err := t.Ctx.Err(); err != nil {
+		return fmt.Errorf("restore aborted: %w", err)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("restore %s %w after %s without completing", restore.Name, ErrWaitTimeout, t.RestoreTimeout)
+	}
+
+	return nil
+}
+
+// failFastCondition returns the first condition with a fail fast reason.
+func (t *RestoreTask) failFastCondition(conditions []metav1.Condition) *metav1.Condition {
+	for i, condition := range conditions {
+		for _, reason := range t.FailFastReasons {
+			if condition.Reason == reason {
+				return &conditions[i]
+			}
+		}
+	}
 	return nil
 }
 
-// PrintRestoreLogs prints logs of pods that ran the restore to stdout.
-// WARNING: Restore logs expose the backup webhook URL.
+// PrintRestoreLogs prints logs of pods that ran the restore to stdout, with URLs redacted as restore
+// logs expose the backup webhook URL.
 func (t *RestoreTask) PrintRestoreLogs(restore k8upv1.Restore) error {
 	podList, err := t.Clientset.CoreV1().Pods(restore.Namespace).List(t.Ctx, metav1.ListOptions{
 		LabelSelector: fmt.Sprintf("batch.kubernetes.io/job-name=restore-%s", restore.Name),
@@ -280,55 +663,66 @@ func (t *RestoreTask) PrintUploadLogs(uploadPod corev1.Pod) error {
 	return nil
 }
 
-func (t *RestoreTask) printPodLogs(podList *corev1.PodList) {
-	for _, pod := range podList.Items {
-		req := t.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{})
-		stream, err := req.Stream(t.Ctx)
-		if err != nil {
-			log.Printf("Failed to get logs: %v", err)
-			continue
-		}
-		defer stream.Close()
-
-		if _, err := io.Copy(log.Writer(), stream); err != nil {
-			log.Printf("Failed to print logs: %v", err)
-		}
-	}
-}
-
-// Cleanup cleans up task resources.
+// Cleanup cleans up task resources. If the task was aborted, the removed resources are reported.
 func (t *RestoreTask) Cleanup(
 	pvc *corev1.PersistentVolumeClaim,
 	restore *k8upv1.Restore,
 	uploadPod *corev1.Pod,
 ) {
+	ctx := t.cleanupCtx()
+	aborted := t.Ctx.Err() != nil
+
+	var orphaned []string
 	if restore != nil {
-		err := t.Client.Delete(t.Ctx, restore)
+		// The restore job is owned by the restore, remove it in the background.
+		err := t.DeleteWithRetry(ctx, restore, client.PropagationPolicy(metav1.DeletePropagationBackground))
 		if err != nil {
 			log.Printf("Failed to clean up restore: %v", err)
+			orphaned = append(orphaned, orphanedResource("restore", restore))
+		} else if aborted {
+			log.Printf("Rolled back restore %s and its job", restore.Name)
 		}
 	}
 
 	if uploadPod != nil {
-		err := t.Client.Delete(t.Ctx, uploadPod)
+		err := t.DeleteWithRetry(ctx, uploadPod)
 		if err != nil {
 			log.Printf("Failed to clean up pod: %v", err)
+			orphaned = append(orphaned, orphanedResource("pod", uploadPod))
+		} else if aborted {
+			log.Printf("Rolled back upload pod %s", uploadPod.Name)
 		}
 	}
 
 	if pvc != nil {
-		err := t.Client.Delete(t.Ctx, pvc)
+		retained := t.retainedPV(ctx, pvc)
+		err := t.DeleteWithRetry(ctx, pvc)
 		if err != nil {
 			log.Printf("Failed to clean up pvc: %v", err)
+			orphaned = append(orphaned, orphanedResource("pvc", pvc))
+		} else if aborted {
+			log.Printf("Rolled back pvc %s", pvc.Name)
+		}
+		if err == nil && retained != "" {
+			log.Printf("WARNING: pv %s of pvc %s has reclaim policy Retain and is kept with its storage, remove it manually or pass -reclaim-retained-pvs", retained, pvc.Name)
+			orphaned = append(orphaned, "pv "+retained)
 		}
 	}
+
+	t.warnOrphaned(orphaned)
+}
+
+// cleanupCtx returns a context for cleaning up, which is not cancelled when the task is aborted.
+func (t *RestoreTask) cleanupCtx() context.Context {
+	return context.WithoutCancel(t.Ctx)
 }
 
-// ArchiveRestore archives and compresses the restored files.
-func (t *RestoreTask) ArchiveRestore(restoreTarget string, archiveTarget string) (*os.File, error) {
+// ArchiveRestore archives and compresses the restored files. It returns the archive and the number
+// of files it contains.
+func (t *RestoreTask) ArchiveRestore(restoreTarget string, archiveTarget string) (*os.File, int, error) {
 	_, err := os.Stat(restoreTarget)
 	if err != nil {
-		return &os.File{}, fmt.Errorf("invaid restore target %s: %v", restoreTarget, err)
+		return &os.File{}, 0, fmt.Errorf("invaid restore target %s: %v", restoreTarget, err)
 	}
 
 	// Specifying the files format as `"{restoreTarget}/": ""` ensures that the restore target dir is
@@ -338,67 +732,392 @@ func (t *RestoreTask) ArchiveRestore(restoreTarget string, archiveTarget string)
 		rTarget: "",
 	})
 	if err != nil {
-		return &os.File{}, fmt.Errorf("failed to parse restore target files: %v", err)
+		return &os.File{}, 0, fmt.Errorf("failed to parse restore target files: %v", err)
+	}
+
+	files, err = t.handleSymlinks(t.Ctx, rTarget, files)
+	if err != nil {
+		return &os.File{}, 0, err
+	}
+
+	files, err = t.handleSpecialFiles(files)
+	if err != nil {
+		return &os.File{}, 0, err
+	}
+
+	// Dotfiles like `.env` and `.htaccess` are archived unless explicitly excluded.
+	if t.ExcludeHidden {
+		files = t.excludeHidden(files)
+	}
+
+	// k8up only passes the restore filter to restic, so excluded paths are restored but not archived.
+	if len(t.ExcludePaths) > 0 {
+		files = t.excludePaths(files)
+	}
+
+	if len(t.IncludeExtensions) > 0 || len(t.ExcludeExtensions) > 0 {
+		files = t.filterExtensions(files)
+	}
+
+	files, err = t.handleCaseCollisions(files)
+	if err != nil {
+		return &os.File{}, 0, err
+	}
+
+	files, err = t.handleSparseFiles(files)
+	if err != nil {
+		return &os.File{}, 0, err
+	}
+
+	fileCount := 0
+	for _, file := range files {
+		if !file.IsDir() {
+			fileCount++
+		}
+	}
+
+	if fileCount == 0 {
+		if t.OnEmpty != OnEmptyWarn {
+			return &os.File{}, 0, ErrEmptyRestore
+		}
+		log.Printf("Warning: %v, uploading an empty archive", ErrEmptyRestore)
+	}
+
+	if t.Reproducible {
+		files = reproducibleFiles(files)
+	}
+
+	if name, ok := t.archiveInfoFileName(files); ok {
+		infoFile, err := t.writeInfoFile()
+		if err != nil {
+			return &os.File{}, 0, fmt.Errorf("failed to create restore info: %v", err)
+		}
+		defer os.Remove(infoFile)
+
+		info, err := archives.FilesFromDisk(t.Ctx, nil, map[string]string{
+			infoFile: name,
+		})
+		if err != nil {
+			return &os.File{}, 0, fmt.Errorf("failed to parse restore info: %v", err)
+		}
+		if t.Reproducible {
+			info = reproducibleFiles(info)
+		}
+		files = append(info, files...)
 	}
 
-	aTarget := filepath.Join(archiveTarget, fmt.Sprintf("restore-%s-t%s.tar.gz", t.Args.BackupId, t.TaskId))
+	aTarget := filepath.Join(archiveTarget, fmt.Sprintf("restore-%s-t%s.%s", t.Args.Snapshot(), t.TaskId, t.archiveFormat()))
 	archive, err := os.Create(aTarget)
 	if err != nil {
-		return &os.File{}, fmt.Errorf("failed to create archive: %v", err)
+		return &os.File{}, 0, fmt.Errorf("failed to create archive: %v", err)
 	}
 	defer archive.Close()
 
-	format := archives.CompressedArchive{
-		Compression: archives.Gz{},
-		Archival:    archives.Tar{},
+	if err := t.setArchivePermissions(archive); err != nil {
+		return &os.File{}, 0, err
 	}
 
 	// Archive and compress the restored files.
-	err = format.Archive(t.Ctx, archive, files)
+	err = t.archiver().Archive(t.Ctx, archive, files)
+	if err != nil {
+		return &os.File{}, 0, fmt.Errorf("failed to archive restore: %v", err)
+	}
+
+	return archive, fileCount, nil
+}
+
+// archiveInfoFileName returns the name of the restore info file in the archive. When the restore
+// contains a file of that name the info file is renamed after the task, tar would otherwise hold two
+// entries of the same name. It returns false when the renamed file is taken as well.
+func (t *RestoreTask) archiveInfoFileName(files []archives.FileInfo) (string, bool) {
+	if t.NoInfoFile {
+		return "", false
+	}
+
+	taken := map[string]bool{}
+	for _, file := range files {
+		taken[file.NameInArchive] = true
+	}
+
+	if !taken[infoFileName] {
+		return infoFileName, true
+	}
+	name := fmt.Sprintf("%s-t%s.txt", strings.TrimSuffix(infoFileName, ".txt"), t.TaskId)
+	if !taken[name] {
+		log.Printf("Warning: the restore contains %s, the restore info is archived as %s", infoFileName, name)
+		return name, true
+	}
+	log.Printf("Warning: the restore contains %s and %s, the restore info is left out of the archive", infoFileName, name)
+	return "", false
+}
+
+// setArchivePermissions applies the configured mode and owner to the archive.
+func (t *RestoreTask) setArchivePermissions(archive *os.File) error {
+	if t.ArchiveMode != 0 {
+		if err := archive.Chmod(t.ArchiveMode); err != nil {
+			return fmt.Errorf("failed to set archive mode: %v", err)
+		}
+	}
+
+	if t.ArchiveUID >= 0 || t.ArchiveGID >= 0 {
+		if os.Geteuid() != 0 {
+			log.Printf("Warning: not running as root, keeping the archive owner")
+			return nil
+		}
+		if err := archive.Chown(t.ArchiveUID, t.ArchiveGID); err != nil {
+			return fmt.Errorf("failed to set archive owner: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// ParseArchiveOwner parses an archive owner in the format UID[:GID].
+func ParseArchiveOwner(owner string) (int, int, error) {
+	uidStr, gidStr, hasGID := strings.Cut(owner, ":")
+	uid, err := strconv.Atoi(uidStr)
+	if err != nil || uid < 0 {
+		return -1, -1, fmt.Errorf("invalid uid %q", uidStr)
+	}
+	if !hasGID {
+		return uid, -1, nil
+	}
+	gid, err := strconv.Atoi(gidStr)
+	if err != nil || gid < 0 {
+		return -1, -1, fmt.Errorf("invalid gid %q", gidStr)
+	}
+	return uid, gid, nil
+}
+
+// writeInfoFile writes a temporary file describing the restore, to be included in the archive.
+func (t *RestoreTask) writeInfoFile() (string, error) {
+	f, err := os.CreateTemp("", "restore-info-*.txt")
 	if err != nil {
-		return &os.File{}, fmt.Errorf("failed to archive restore: %v", err)
+		return "", err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "Snapshot:       %s\n", t.Args.BackupId)
+	if t.Args.Snapshot() != t.Args.BackupId {
+		fmt.Fprintf(f, "Snapshot ID:    %s\n", t.Args.Snapshot())
+	}
+	if len(t.Args.MergeBackupIds) > 0 {
+		fmt.Fprintf(f, "Merged:         %s\n", strings.Join(t.Args.MergeBackupIds, ", "))
 	}
+	fmt.Fprintf(f, "Restore filter: %s\n", t.Args.RestoreFilter)
+	fmt.Fprintf(f, "Task ID:        %s\n", t.TaskId)
+	if !t.Reproducible {
+		fmt.Fprintf(f, "Archived at:    %s\n", time.Now().UTC().Format(time.RFC3339))
+	}
+	if t.Args.Description != "" {
+		fmt.Fprintf(f, "Description:    %s\n", t.Args.Description)
+	}
+
+	return f.Name(), nil
+}
 
-	return archive, nil
+// ValidateSSHKey checks the SSH key used to get a Lagoon token is readable. A missing key is allowed
+// when an SSH agent is available, the token retrieval falls back to it.
+func (t *RestoreTask) ValidateSSHKey() error {
+	f, err := os.Open(t.SSHKeyPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && os.Getenv("SSH_AUTH_SOCK") != "" {
+			log.Printf("SSH key %s not found, using ssh-agent", t.SSHKeyPath)
+			return nil
+		}
+		return err
+	}
+	return f.Close()
 }
 
-// UploadArchiveToLagoon uploads a given file to the Lagoon API.
-func (t *RestoreTask) UploadArchiveToLagoon(archive *os.File) error {
-	token, err := sshtoken.RetrieveToken("/var/run/secrets/lagoon/ssh/ssh-privatekey", t.TokenHost, t.TokenPort, nil, nil, false)
+// UploadArchiveToLagoon uploads a given file to the Lagoon API. It returns the file name stored on
+// the task, or an empty string if the API did not report it.
+func (t *RestoreTask) UploadArchiveToLagoon(archive *os.File) (string, error) {
+	lc, err := t.lagoonClient()
 	if err != nil {
-		return fmt.Errorf("failed to get Lagoon token: %v", err)
+		return "", err
+	}
+
+	ctx, cancel := t.uploadContext()
+	defer cancel()
+
+	name, _, err := t.uploadToTasks(ctx, lc, archive.Name())
+	return name, err
+}
+
+// UploadFilesToLagoon uploads restored files individually to the Lagoon API, so they can be
+// downloaded separately. It returns the names of the uploaded and failed files. Failed uploads
+// don't stop uploads of the other files, unless the upload atomicity is all-or-nothing, then the
+// uploaded files are removed again from the tasks which had no files before.
+func (t *RestoreTask) UploadFilesToLagoon(files []string) ([]string, []string, error) {
+	lc, err := t.lagoonClient()
+	if err != nil {
+		return nil, files, err
+	}
+
+	ctx, cancel := t.uploadContext()
+	defer cancel()
+
+	// Only tasks without files can be rolled back, the Lagoon API removes all files of a task.
+	var hadFiles map[string]bool
+	if t.UploadAtomicity == UploadAtomicityAllOrNothing {
+		hadFiles = t.tasksWithFiles(ctx, lc)
+	}
+
+	var uploaded, failed []string
+	var errs []error
+	uploadedTo := map[string]bool{}
+	for _, file := range files {
+		_, ids, err := t.uploadToTasks(ctx, lc, file)
+		for _, id := range ids {
+			uploadedTo[id] = true
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(file), err))
+			failed = append(failed, filepath.Base(file))
+			if t.UploadAtomicity == UploadAtomicityAllOrNothing {
+				break
+			}
+			continue
+		}
+		log.Printf("Uploaded %s", filepath.Base(file))
+		uploaded = append(uploaded, filepath.Base(file))
+	}
+
+	if len(failed) > 0 && len(uploadedTo) > 0 && t.UploadAtomicity == UploadAtomicityAllOrNothing {
+		log.Printf("Removing the files uploaded before %s failed", failed[0])
+		remove, keep := rollbackTaskIds(append([]string{t.TaskId}, t.AdditionalTaskIds...), uploadedTo, hadFiles)
+		var rollbackErrs []error
+		for _, id := range keep {
+			rollbackErrs = append(rollbackErrs, fmt.Errorf("can't remove uploaded files from Lagoon task %s, it had files before the upload which would be removed too", id))
+		}
+		for _, id := range remove {
+			if err := deleteTaskFiles(ctx, lc, id); err != nil {
+				rollbackErrs = append(rollbackErrs, fmt.Errorf("failed to remove uploaded files from Lagoon task %s: %w", id, err))
+			}
+		}
+		if len(rollbackErrs) > 0 {
+			errs = append(errs, rollbackErrs...)
+		} else {
+			uploaded = nil
+		}
+	}
+
+	return uploaded, failed, errors.Join(errs...)
+}
+
+// lagoonClient creates a Lagoon API client with a token retrieved with the SSH key.
+func (t *RestoreTask) lagoonClient() (*lclient.Client, error) {
+	token, err := sshtoken.RetrieveToken(t.SSHKeyPath, t.TokenHost, t.TokenPort, nil, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Lagoon token: %v", err)
 	}
 
 	if token == "" {
-		return fmt.Errorf("failed to get Lagoon token")
+		return nil, fmt.Errorf("failed to get Lagoon token")
 	}
 
-	taskId, _ := strconv.Atoi(t.TaskId)
-	lc := lclient.New(
+	return lclient.New(
 		t.APIHost+"/graphql",
 		fmt.Sprintf("RestoreTask-%s", TaskVersion),
 		"0.x",
 		&token,
-		true)
-	_, err = lagoon.UploadFilesForTask(context.TODO(), taskId, []string{archive.Name()}, lc)
+		true), nil
+}
+
+// uploadContext returns the context of uploads to Lagoon, limited by the upload timeout.
+func (t *RestoreTask) uploadContext() (context.Context, context.CancelFunc) {
+	if t.UploadTimeout > 0 {
+		return context.WithTimeout(t.Ctx, t.UploadTimeout)
+	}
+	return context.WithCancel(t.Ctx)
+}
+
+// uploadToTasks uploads a file to the task and the additional tasks. It returns the file name
+// stored on the task, or an empty string if the API did not report it, and the IDs of the tasks the
+// file was uploaded to.
+func (t *RestoreTask) uploadToTasks(ctx context.Context, lc *lclient.Client, file string) (string, []string, error) {
+	// The file is attached to every task, failed uploads don't stop uploads to the others.
+	var uploadedName string
+	var uploadedTo []string
+	var errs []error
+	for i, id := range append([]string{t.TaskId}, t.AdditionalTaskIds...) {
+		name, err := uploadToTask(ctx, lc, id, file)
+		if errors.Is(err, context.DeadlineExceeded) {
+			errs = append(errs, fmt.Errorf("upload to Lagoon task %s timed out after %s: %w", id, t.UploadTimeout, err))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to upload restore to Lagoon task %s: %w", id, err))
+			continue
+		}
+		uploadedTo = append(uploadedTo, id)
+		if i == 0 {
+			uploadedName = name
+		} else {
+			log.Printf("Uploaded restore to additional Lagoon task %s", id)
+		}
+	}
+
+	return uploadedName, uploadedTo, errors.Join(errs...)
+}
+
+// uploadToTask uploads a file to the files of a Lagoon task and returns its file name. The Lagoon
+// client doesn't cancel uploads, so an upload still running when ctx is done is abandoned.
+func uploadToTask(ctx context.Context, lc *lclient.Client, id string, file string) (string, error) {
+	if err := checkTaskOpen(ctx, lc, id); err != nil {
+		return "", err
+	}
+
+	type uploaded struct {
+		task *schema.Task
+		err  error
+	}
+	done := make(chan uploaded, 1)
+	go func() {
+		taskId, _ := strconv.Atoi(id)
+		result, err := lagoon.UploadFilesForTask(ctx, taskId, []string{file}, lc)
+		done <- uploaded{result, err}
+	}()
+
+	var result *schema.Task
+	var err error
+	select {
+	case u := <-done:
+		result, err = u.task, u.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
 	if err != nil {
-		return fmt.Errorf("failed to upload restore to Lagoon task: %v", err)
+		// The task may have been closed while uploading, which explains the failure better.
+		if closedErr := checkTaskOpen(ctx, lc, id); closedErr != nil {
+			return "", closedErr
+		}
+		return "", err
 	}
 
-	return nil
+	// The API only reports file names, it doesn't return download links.
+	name := filepath.Base(file)
+	for _, uploaded := range result.Files {
+		if strings.HasSuffix(uploaded.Filename, name) {
+			return uploaded.Filename, nil
+		}
+	}
+
+	return "", nil
 }
 
 // WaitForUpload waits for the upload to complete or timeout.
 func (t *RestoreTask) WaitForUpload(pod corev1.Pod) error {
-	w, err := t.WatchingClient.Watch(t.Ctx, &corev1.PodList{}, &client.ListOptions{
-		Namespace:     pod.Namespace,
-		FieldSelector: fields.OneTermEqualSelector("metadata.name", pod.Name),
-	})
+	w, err := t.watchObject(&pod, &corev1.PodList{})
 	if err != nil {
 		return fmt.Errorf("failed to watch upload: %w", err)
 	}
 	defer w.Stop()
 
+	ctx, cancel := t.stopAfter(w, "pod "+pod.Name, t.UploadPodTimeout)
+	defer cancel()
+
 	for event := range w.ResultChan() {
 		uploadWatch, ok := event.Object.(*corev1.Pod)
 		if !ok {
@@ -414,6 +1133,13 @@ func (t *RestoreTask) WaitForUpload(pod corev1.Pod) error {
 
 	w.Stop()
 
+	if err := t.Ctx.Err(); err != nil {
+		return fmt.Errorf("upload aborted: %w", err)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("pod %s %w after %s without completing", pod.Name, ErrWaitTimeout, t.UploadPodTimeout)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,176 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// sourceNamespaceAnnotation records the environment namespace restored into a restore namespace.
+	sourceNamespaceAnnotation = "lagoon.sh/restore-source-namespace"
+	// sshKeySecret is the secret with the Lagoon SSH key mounted into the upload pod.
+	sshKeySecret = "lagoon-sshkey"
+	// deployerServiceAccount is the service account of pods started by the task.
+	deployerServiceAccount = "lagoon-deployer"
+)
+
+// UseRestoreNamespace moves all resources created by the task to another namespace, eg a throwaway
+// namespace for DR drills. Backups, snapshots and the schedule are still read from the environment
+// namespace. With create the namespace is created if it does not exist, and removed again by
+// CleanupRestoreNamespace.
+func (t *RestoreTask) UseRestoreNamespace(namespace string, create bool) error {
+	if namespace == t.SourceNamespace {
+		return nil
+	}
+
+	var ns corev1.Namespace
+	err := t.ClusterClient.Get(t.Ctx, client.ObjectKey{Name: namespace}, &ns)
+	switch {
+	case apierrors.IsNotFound(err) && create:
+		ns = corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: namespace,
+				Annotations: map[string]string{
+					sourceNamespaceAnnotation: t.SourceNamespace,
+				},
+			},
+		}
+		if err := t.ClusterClient.Create(t.Ctx, &ns); err != nil {
+			return fmt.Errorf("failed to create restore namespace %s: %w", namespace, err)
+		}
+		t.createdNamespace = true
+		log.Printf("Created restore namespace %s", namespace)
+	case apierrors.IsNotFound(err):
+		return fmt.Errorf("restore namespace %s does not exist, pass -create-restore-namespace to create it", namespace)
+	case err != nil:
+		return fmt.Errorf("failed to get restore namespace %s: %w", namespace, err)
+	default:
+		if err := checkNamespaceActive(ns); err != nil {
+			return err
+		}
+	}
+
+	t.Namespace = namespace
+	t.Client = client.NewNamespacedClient(t.ClusterClient, namespace)
+	return nil
+}
+
+// CopySecrets copies the secrets needed by the restore job and upload pod from the environment
+// namespace into the restore namespace: the repository credentials of the schedule and S3 restore
+// settings, and the Lagoon SSH key.
+func (t *RestoreTask) CopySecrets(schedule k8upv1.Schedule) error {
+	if t.Namespace == t.SourceNamespace {
+		return nil
+	}
+
+	names := []string{sshKeySecret}
+	if schedule.Spec.Backend != nil {
+		for _, source := range schedule.Spec.Backend.GetCredentialEnv() {
+			if source != nil && source.SecretKeyRef != nil {
+				names = append(names, source.SecretKeyRef.Name)
+			}
+		}
+	}
+	if t.GlobalS3Secret != "" {
+		names = append(names, t.GlobalS3Secret)
+	}
+	if s3 := t.S3Restore; s3 != nil {
+		for _, ref := range []*corev1.SecretKeySelector{s3.AccessKeyIDSecretRef, s3.SecretAccessKeySecretRef} {
+			if ref != nil {
+				names = append(names, ref.Name)
+			}
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range slices.Compact(names) {
+		if err := t.copySecret(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *RestoreTask) copySecret(name string) error {
+	var secret corev1.Secret
+	if err := t.SourceClient.Get(t.Ctx, client.ObjectKey{Name: name}, &secret); err != nil {
+		return fmt.Errorf("failed to get secret %s: %w", name, err)
+	}
+
+	restoreSecret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				sourceNamespaceAnnotation: t.SourceNamespace,
+			},
+		},
+		Type: secret.Type,
+		Data: secret.Data,
+	}
+	if err := t.Client.Create(t.Ctx, &restoreSecret); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			log.Printf("Warning: secret %s already exists in restore namespace %s, it is used as is", name, t.Namespace)
+			return nil
+		}
+		return fmt.Errorf("failed to copy secret %s: %w", name, err)
+	}
+	t.copiedSecrets = append(t.copiedSecrets, name)
+
+	return nil
+}
+
+// CleanupRestoreNamespace removes the restore namespace if the task created it, otherwise only the
+// secrets copied into it.
+func (t *RestoreTask) CleanupRestoreNamespace() {
+	ctx := t.cleanupCtx()
+
+	if t.createdNamespace {
+		ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: t.Namespace}}
+		if err := t.ClusterClient.Delete(ctx, &ns); err != nil && !apierrors.IsNotFound(err) {
+			log.Printf("Failed to clean up restore namespace %s: %v", t.Namespace, err)
+			return
+		}
+		log.Printf("Removed restore namespace %s", t.Namespace)
+		return
+	}
+
+	for _, name := range t.copiedSecrets {
+		secret := corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if err := t.Client.Delete(ctx, &secret); err != nil && !apierrors.IsNotFound(err) {
+			log.Printf("Failed to clean up secret %s: %v", name, err)
+		}
+	}
+}
+
+// PodServiceAccount returns the service account of pods started by the task. The Lagoon deployer
+// account only exists in environment namespaces, restore namespaces use the default account.
+func (t *RestoreTask) PodServiceAccount() string {
+	if t.Namespace != t.SourceNamespace {
+		return ""
+	}
+	return deployerServiceAccount
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// StartSizePod starts a pod summing the sizes of the files of the snapshot matching the restore
+// filter with `restic ls`, `restic stats` can't apply the restore filter. The size is the termination
+// message of the pod, read it with ReadRestoreSize.
+func (t *RestoreTask) StartSizePod(image string) (corev1.Pod, error) {
+	// A failed listing is marked in the output, it would otherwise count as zero bytes.
+	script := `(restic ls --json --recursive --no-lock --no-cache "$1" "$2" || echo failed) | ` +
+		`awk '/"type":"file"/ && match($0, /"size":[0-9]+/) { n += substr($0, RSTART + 7, RLENGTH - 7) } ` +
+		`/^failed$/ { exit 1 } END { printf "%.0f\n", n }' > ` + terminationMessagePath
+	command := []string{"sh", "-c", script, "sh", t.Args.Snapshot(), t.Args.RestoreFilter}
+
+	return t.startResticPod(fmt.Sprintf("size-%s", t.TaskKey), image, command)
+}
+
+// ReadRestoreSize reads the restore size in bytes reported by a finished size pod.
+func ReadRestoreSize(pod corev1.Pod) (int64, error) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated == nil || status.State.Terminated.Message == "" {
+			continue
+		}
+
+		size, err := strconv.ParseInt(strings.TrimSpace(status.State.Terminated.Message), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse restore size: %w", err)
+		}
+		return size, nil
+	}
+
+	return 0, fmt.Errorf("size pod did not report a restore size")
+}
+
+// CheckRestoreSize ensures restored files of the size fit into the restore PVC. Filesystem metadata
+// takes some of the PVC, so restores filling more than 90% of it are a warning.
+func (t *RestoreTask) CheckRestoreSize(size int64) error {
+	quantity, err := resource.ParseQuantity(t.PVCSize)
+	if err != nil {
+		return fmt.Errorf("invalid pvc size %q: %w", t.PVCSize, err)
+	}
+	capacity := quantity.Value()
+
+	if size > capacity {
+		return fmt.Errorf("restored files need %s, more than the %s restore pvc, pass a larger -pvc-size", humanize.IBytes(uint64(size)), t.PVCSize)
+	}
+	if size > capacity/10*9 {
+		log.Printf("Warning: restored files need %s of the %s restore pvc, the restore may not fit, consider a larger -pvc-size", humanize.IBytes(uint64(size)), t.PVCSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestReadRestoreSize(t *testing.T) {
+	terminated := func(message string) corev1.Pod {
+		return corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: message}}},
+		}}}
+	}
+
+	tests := []struct {
+		name    string
+		pod     corev1.Pod
+		want    int64
+		wantErr bool
+	}{
+		{name: "size", pod: terminated("1073741829\n"), want: 1073741829},
+		{name: "empty restore", pod: terminated("0\n"), want: 0},
+		{name: "no message", pod: terminated(""), wantErr: true},
+		{name: "invalid message", pod: terminated("failed"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadRestoreSize(tt.pod)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadRestoreSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReadRestoreSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckRestoreSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		pvcSize string
+		size    int64
+		wantErr bool
+	}{
+		{name: "fits", pvcSize: "1Gi", size: 512 << 20},
+		{name: "nearly full", pvcSize: "1Gi", size: 1000 << 20},
+		{name: "exactly full", pvcSize: "1Gi", size: 1 << 30},
+		{name: "too large", pvcSize: "1Gi", size: 1<<30 + 1, wantErr: true},
+		{name: "decimal size", pvcSize: "50G", size: 50_000_000_001, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := RestoreTask{PVCSize: tt.pvcSize}
+			if err := task.CheckRestoreSize(tt.size); (err != nil) != tt.wantErr {
+				t.Errorf("CheckRestoreSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// terminationMessagePath is the default path Kubernetes reads a container's termination message from.
+const terminationMessagePath = "/dev/termination-log"
+
+// maxTerminationMessage is the size Kubernetes truncates termination messages to.
+const maxTerminationMessage = 4096
+
+// UploadResult describes the archive created and uploaded by the upload pod.
+type UploadResult struct {
+	Snapshot string `json:"snapshot"`
+	Archive  string `json:"archive"`
+	Files    int    `json:"files"`
+	Bytes    int64  `json:"bytes"`
+	Checksum string `json:"checksum"`
+	Skipped  bool   `json:"skipped,omitempty"`
+	// TaskClosed is set when the Lagoon task no longer accepted the upload.
+	TaskClosed bool `json:"taskClosed,omitempty"`
+	// Uploaded and Failed are the files uploaded individually and the files which failed to upload.
+	// They are shortened to fit the termination message, UploadedCount and FailedCount are the
+	// number of files of the complete lists.
+	Uploaded      []string `json:"uploaded,omitempty"`
+	Failed        []string `json:"failed,omitempty"`
+	UploadedCount int      `json:"uploadedCount,omitempty"`
+	FailedCount   int      `json:"failedCount,omitempty"`
+	// Phases are the timings of the upload pod phases, to trace them in the parent task.
+	Phases []Phase `json:"phases,omitempty"`
+}
+
+// WriteUploadResult saves the upload result as the container termination message so the parent
+// task can read it from the pod status. It is a no-op when not running in a pod.
+func WriteUploadResult(result UploadResult) error {
+	if _, err := os.Stat(terminationMessagePath); err != nil {
+		return nil
+	}
+
+	data, err := marshalUploadResult(result)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(terminationMessagePath, data, 0644)
+}
+
+// marshalUploadResult marshals the upload result, dropping files from the end of the longer of the
+// uploaded and failed lists until it fits the termination message. Kubernetes would otherwise
+// truncate it to invalid JSON.
+func marshalUploadResult(result UploadResult) ([]byte, error) {
+	result.UploadedCount = len(result.Uploaded)
+	result.FailedCount = len(result.Failed)
+	for {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal upload result: %w", err)
+		}
+		if len(data) <= maxTerminationMessage {
+			return data, nil
+		}
+
+		switch {
+		case len(result.Uploaded) > 0 && len(result.Uploaded) >= len(result.Failed):
+			result.Uploaded = result.Uploaded[:len(result.Uploaded)-1]
+		case len(result.Failed) > 0:
+			result.Failed = result.Failed[:len(result.Failed)-1]
+		case len(result.Phases) > 0:
+			result.Phases = nil
+		default:
+			return nil, fmt.Errorf("upload result of %d bytes exceeds the termination message limit of %d bytes", len(data), maxTerminationMessage)
+		}
+	}
+}
+
+// FileList lists the files of a shortened list of count files, noting how many were left out.
+func FileList(files []string, count int) string {
+	list := strings.Join(files, ", ")
+	if count > len(files) {
+		list += fmt.Sprintf(" and %d more, see the upload logs", count-len(files))
+	}
+	return list
+}
+
+// ReadUploadResult reads the upload result from the termination message of a finished upload pod.
+func ReadUploadResult(pod corev1.Pod) (*UploadResult, error) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated == nil || status.State.Terminated.Message == "" {
+			continue
+		}
+
+		var result UploadResult
+		if err := json.Unmarshal([]byte(status.State.Terminated.Message), &result); err != nil {
+			return nil, fmt.Errorf("failed to parse upload result: %w", err)
+		}
+		return &result, nil
+	}
+
+	return nil, fmt.Errorf("upload pod did not report a result")
+}
+
+// ChecksumFile calculates the sha256 checksum of a file.
+func ChecksumFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
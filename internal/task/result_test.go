@@ -0,0 +1,69 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMarshalUploadResultFitsTerminationMessage(t *testing.T) {
+	var uploaded, failed []string
+	for i := range MaxUploadFilesLimit {
+		uploaded = append(uploaded, fmt.Sprintf("%s-%03d.sql.gz", strings.Repeat("u", 200), i))
+		failed = append(failed, fmt.Sprintf("%s-%03d.sql.gz", strings.Repeat("f", 200), i))
+	}
+
+	data, err := marshalUploadResult(UploadResult{Snapshot: "1a2b3c4d", Uploaded: uploaded, Failed: failed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > maxTerminationMessage {
+		t.Fatalf("upload result is %d bytes, more than %d", len(data), maxTerminationMessage)
+	}
+
+	var result UploadResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.UploadedCount != len(uploaded) || result.FailedCount != len(failed) {
+		t.Errorf("counts are %d uploaded and %d failed, want %d and %d", result.UploadedCount, result.FailedCount, len(uploaded), len(failed))
+	}
+	if len(result.Uploaded) == 0 || len(result.Uploaded) >= len(uploaded) {
+		t.Errorf("kept %d of %d uploaded files", len(result.Uploaded), len(uploaded))
+	}
+}
+
+func TestMarshalUploadResultKeepsShortLists(t *testing.T) {
+	data, err := marshalUploadResult(UploadResult{Uploaded: []string{"a.sql"}, Failed: []string{"b.sql"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result UploadResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if got := FileList(result.Uploaded, result.UploadedCount); got != "a.sql" {
+		t.Errorf("uploaded files are %q", got)
+	}
+	if got := FileList(result.Failed, result.FailedCount); got != "b.sql" {
+		t.Errorf("failed files are %q", got)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// snapshotAnnotation records the snapshot restored into a PVC.
+	snapshotAnnotation = "lagoon.sh/restore-snapshot"
+	// filterAnnotation records the restore filter of the restore into a PVC.
+	filterAnnotation = "lagoon.sh/restore-filter"
+)
+
+// restorePVCAnnotations returns the annotations of a restore PVC, recording what is restored into
+// it so a restore is only resumed into it with the same snapshot and filter, and the custom PVC
+// annotations.
+func (t *RestoreTask) restorePVCAnnotations() map[string]string {
+	annotations := BackupExcludedAnnotations()
+	for key, value := range t.PVCAnnotations {
+		annotations[key] = value
+	}
+	annotations[snapshotAnnotation] = t.Args.Snapshot()
+	annotations[filterAnnotation] = t.Args.RestoreFilter
+	return annotations
+}
+
+// ResumableRestorePVC gets a restore PVC kept by a previous failed restore of this task. It returns
+// false if there is none, and an error if it holds a restore of another snapshot or filter.
+func (t *RestoreTask) ResumableRestorePVC(name string) (corev1.PersistentVolumeClaim, bool, error) {
+	var pvc corev1.PersistentVolumeClaim
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: name}, &pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return pvc, false, nil
+		}
+		return pvc, false, fmt.Errorf("failed to get restore destination: %w", err)
+	}
+
+	snapshot, filter := pvc.Annotations[snapshotAnnotation], pvc.Annotations[filterAnnotation]
+	if snapshot != t.Args.Snapshot() || filter != t.Args.RestoreFilter {
+		return pvc, false, fmt.Errorf("pvc %s holds a restore of %s from backup %s, it can't be resumed", name, filter, snapshot)
+	}
+
+	return pvc, true, nil
+}
+
+// RemoveStaleRestore removes a Restore left by a previous failed restore of this task, and waits
+// until it is gone so a new one can be created.
+func (t *RestoreTask) RemoveStaleRestore() error {
+	restore := k8upv1.Restore{ObjectMeta: metav1.ObjectMeta{Name: t.TaskKey}}
+	err := t.Client.Delete(t.Ctx, &restore, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove previous restore: %w", err)
+	}
+
+	log.Printf("Removing previous restore %s", restore.Name)
+	return wait.PollUntilContextTimeout(t.Ctx, 2*time.Second, time.Minute, true, func(ctx context.Context) (bool, error) {
+		err := t.Client.Get(ctx, client.ObjectKey{Name: restore.Name}, &restore)
+		return apierrors.IsNotFound(err), nil
+	})
+}
+
+// ReusableRestore finds a completed restore kept after its upload failed, by the task ID of the
+// task that ran it, eg `127` or `127-r1` for a retry attempt. It returns nil if there is none, and
+// an error if the restore doesn't match the requested snapshot and filter or did not succeed.
+func (t *RestoreTask) ReusableRestore(taskId string) (*corev1.PersistentVolumeClaim, *k8upv1.Restore, error) {
+	key := fmt.Sprintf("%s-%s", t.ResourcePrefix, taskId)
+
+	var pvc corev1.PersistentVolumeClaim
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: fmt.Sprintf("restore-target-%s", key)}, &pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to get previous restore destination: %w", err)
+	}
+
+	var restore k8upv1.Restore
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: key}, &restore); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("restore destination %s exists without a restore, it must be removed before restoring again", pvc.Name)
+		}
+		return nil, nil, fmt.Errorf("failed to get previous restore: %w", err)
+	}
+
+	if restore.Spec.Snapshot != t.Args.Snapshot() || restore.Spec.RestoreFilter != t.Args.RestoreFilter {
+		return nil, nil, fmt.Errorf("previous restore %s of %s from backup %s does not match the requested restore", restore.Name, restore.Spec.RestoreFilter, restore.Spec.Snapshot)
+	}
+
+	if !restore.Status.HasSucceeded() {
+		return nil, nil, fmt.Errorf("previous restore %s did not complete successfully", restore.Name)
+	}
+
+	return &pvc, &restore, nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"strings"
+	"testing"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// keptRestore returns the restore and PVC kept by the task with the task ID.
+func keptRestore(taskId string, snapshot string, filter string, succeeded bool) []client.Object {
+	status := metav1.ConditionFalse
+	reason := "Failed"
+	if succeeded {
+		status, reason = metav1.ConditionTrue, string(k8upv1.ReasonSucceeded)
+	}
+
+	restore := &k8upv1.Restore{
+		ObjectMeta: metav1.ObjectMeta{Name: "rft-" + taskId},
+		Spec:       k8upv1.RestoreSpec{Snapshot: snapshot, RestoreFilter: filter},
+	}
+	restore.Status.Conditions = []metav1.Condition{{
+		Type:   k8upv1.ConditionCompleted.String(),
+		Status: status,
+		Reason: reason,
+	}}
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "restore-target-rft-" + taskId}}
+	return []client.Object{restore, pvc}
+}
+
+func TestReusableRestore(t *testing.T) {
+	tests := []struct {
+		name    string
+		objs    []client.Object
+		taskId  string
+		found   bool
+		wantErr string
+	}{
+		{name: "none kept", taskId: "126"},
+		{name: "kept by a previous task", objs: keptRestore("126", "1a2b3c4d", "/nginx", true), taskId: "126", found: true},
+		{name: "kept by a retry attempt", objs: keptRestore("126-r1", "1a2b3c4d", "/nginx", true), taskId: "126-r1", found: true},
+		{name: "kept by another task", objs: keptRestore("125", "1a2b3c4d", "/nginx", true), taskId: "126"},
+		{name: "other snapshot", objs: keptRestore("126", "5e6f7a8b", "/nginx", true), taskId: "126", wantErr: "does not match"},
+		{name: "other filter", objs: keptRestore("126", "1a2b3c4d", "/php", true), taskId: "126", wantErr: "does not match"},
+		{name: "failed restore", objs: keptRestore("126", "1a2b3c4d", "/nginx", false), taskId: "126", wantErr: "did not complete"},
+		{name: "pvc without restore", objs: keptRestore("126", "1a2b3c4d", "/nginx", true)[1:], taskId: "126", wantErr: "without a restore"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := newFakeTask(t, tt.objs...)
+			pvc, restore, err := task.ReusableRestore(tt.taskId)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReusableRestore() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found := pvc != nil && restore != nil; found != tt.found {
+				t.Fatalf("ReusableRestore() found = %v, want %v", found, tt.found)
+			}
+			if tt.found && restore.Name != "rft-"+tt.taskId {
+				t.Errorf("ReusableRestore() restore = %s, want rft-%s", restore.Name, tt.taskId)
+			}
+		})
+	}
+}
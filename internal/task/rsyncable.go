@@ -0,0 +1,92 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/mholt/archives"
+)
+
+// rsyncWindow is the size of the rolling window of rsyncable gzip, the same as `gzip --rsyncable`.
+const rsyncWindow = 4096
+
+// rsyncableGz is gzip compression with rsyncable framing: the compressor is flushed at boundaries
+// determined by the content, so unchanged regions of similar archives compress to the same bytes
+// and transfer efficiently with rsync or deduplicating stores. The archive is slightly larger.
+type rsyncableGz struct {
+	archives.Gz
+}
+
+func (gz rsyncableGz) OpenWriter(w io.Writer) (io.WriteCloser, error) {
+	zw, err := gzip.NewWriterLevel(w, gz.CompressionLevel)
+	if err != nil {
+		return nil, err
+	}
+	return &rsyncableWriter{zw: zw}, nil
+}
+
+// rsyncableWriter flushes the gzip writer whenever the rolling sum of the last rsyncWindow bytes
+// is a multiple of rsyncWindow, at least rsyncWindow bytes after the previous flush. The sum stays
+// a multiple for every byte of runs of the same byte, eg zero-filled files, which would otherwise
+// flush after every byte.
+type rsyncableWriter struct {
+	zw         *gzip.Writer
+	window     [rsyncWindow]byte
+	pos        int
+	filled     bool
+	sum        uint32
+	sinceFlush int
+}
+
+func (r *rsyncableWriter) Write(p []byte) (int, error) {
+	written := 0
+	for i, b := range p {
+		if r.filled {
+			r.sum -= uint32(r.window[r.pos])
+		}
+		r.sum += uint32(b)
+		r.window[r.pos] = b
+		r.pos++
+		if r.pos == rsyncWindow {
+			r.pos = 0
+			r.filled = true
+		}
+
+		r.sinceFlush++
+
+		if r.filled && r.sum%rsyncWindow == 0 && r.sinceFlush >= rsyncWindow {
+			r.sinceFlush = 0
+			n, err := r.zw.Write(p[written : i+1])
+			written += n
+			if err != nil {
+				return written, err
+			}
+			if err := r.zw.Flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	n, err := r.zw.Write(p[written:])
+	return written + n, err
+}
+
+func (r *rsyncableWriter) Close() error {
+	return r.zw.Close()
+}
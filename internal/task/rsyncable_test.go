@@ -0,0 +1,59 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/mholt/archives"
+)
+
+func TestRsyncableGzZeroRun(t *testing.T) {
+	input := make([]byte, 4<<20)
+
+	var buf bytes.Buffer
+	w, err := rsyncableGz{Gz: archives.Gz{CompressionLevel: gzip.DefaultCompression}}.OpenWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(input); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// At most one flush per window, a flush after every byte would grow the archive beyond the input.
+	if max := len(input) / rsyncWindow * 64; buf.Len() > max {
+		t.Errorf("compressed %d zero bytes to %d bytes, want at most %d", len(input), buf.Len(), max)
+	}
+
+	r, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(output, input) {
+		t.Errorf("decompressed %d bytes, want %d zero bytes", len(output), len(input))
+	}
+}
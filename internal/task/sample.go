@@ -0,0 +1,125 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ParseVerifySample parses the sample of restored files read back, either a number of files or a
+// percentage like `5%`. It returns the number of files or the percentage, the other is zero.
+func ParseVerifySample(sample string) (int, int, error) {
+	if value, ok := strings.CutSuffix(sample, "%"); ok {
+		percent, err := strconv.Atoi(value)
+		if err != nil || percent < 1 || percent > 100 {
+			return 0, 0, fmt.Errorf("invalid sample percentage %q, must be between 1%% and 100%%", sample)
+		}
+		return 0, percent, nil
+	}
+
+	files, err := strconv.Atoi(sample)
+	if err != nil || files < 1 {
+		return 0, 0, fmt.Errorf("invalid sample %q, must be a number of files or a percentage", sample)
+	}
+	return files, 0, nil
+}
+
+// VerifySample reads back a random sample of the restored regular files, either a number of files
+// or a percentage, to catch restores which are silently broken without reading every file. It
+// returns the number of sampled and restored files, the sampled files which are empty, and an error
+// listing the sampled files which could not be read completely. Empty files may be legitimate, so
+// they are only reported.
+func VerifySample(restoreTarget string, files int, percent int) (int, int, []string, error) {
+	var sample []string
+	total := 0
+	err := filepath.WalkDir(restoreTarget, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		total++
+		switch {
+		case percent > 0:
+			if percent == 100 || rand.IntN(100) < percent {
+				sample = append(sample, path)
+			}
+		case len(sample) < files:
+			sample = append(sample, path)
+		default:
+			// Reservoir sampling keeps every file equally likely without listing them all first.
+			if i := rand.IntN(total); i < files {
+				sample[i] = path
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to sample restored files: %w", err)
+	}
+
+	var empty, unreadable []string
+	for _, path := range sample {
+		size, err := readBack(path)
+		if err != nil {
+			unreadable = append(unreadable, fmt.Sprintf("%s (%v)", path, err))
+			continue
+		}
+		if size == 0 {
+			empty = append(empty, path)
+		}
+	}
+
+	if len(unreadable) > 0 {
+		return len(sample), total, empty, fmt.Errorf("%d sampled files could not be read: %s", len(unreadable), strings.Join(unreadable, ", "))
+	}
+
+	return len(sample), total, empty, nil
+}
+
+// readBack reads a file completely and checks it has the size of its metadata, returning the size.
+func readBack(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(io.Discard, f)
+	if err != nil {
+		return n, err
+	}
+	if n != info.Size() {
+		return n, fmt.Errorf("read %d of %d bytes", n, info.Size())
+	}
+
+	return n, nil
+}
@@ -0,0 +1,30 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import "fmt"
+
+// Section marks the start of a phase in the task log with `::section::NAME`, so the Lagoon UI can
+// fold or highlight the phases of long task logs, when log sections are enabled. The phase is added
+// to JSON logs.
+func (t *RestoreTask) Section(name string) {
+	logPhase.Store(name)
+	if !t.LogSections {
+		return
+	}
+	fmt.Printf("::section::%s\n", name)
+}
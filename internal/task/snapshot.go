@@ -0,0 +1,182 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+)
+
+// ListSnapshots lists the snapshots k8up has synced to the namespace.
+func (t *RestoreTask) ListSnapshots() ([]k8upv1.Snapshot, error) {
+	var snapshots k8upv1.SnapshotList
+	if err := t.SourceClient.List(t.Ctx, &snapshots); err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	return snapshots.Items, nil
+}
+
+// LatestSnapshot is the snapshot ID resolving to the latest synced snapshot in the snapshot window.
+const LatestSnapshot = "latest"
+
+// ResolveSnapshot expands a (short) snapshot ID to the full ID of the matching snapshot. If the
+// prefix matches multiple snapshots it fails, listing the matches. If no snapshot matches the ID is
+// returned as is, because k8up may not have synced the latest snapshots yet. `latest` resolves to
+// the latest snapshot taken within the snapshot window.
+func (t *RestoreTask) ResolveSnapshot(id string) (string, error) {
+	snapshots, err := t.ListSnapshots()
+	if err != nil {
+		return "", err
+	}
+
+	if id == LatestSnapshot {
+		return t.latestSnapshot(snapshots)
+	}
+
+	var matches []string
+	for _, snapshot := range snapshots {
+		if snapshot.Spec.ID == nil {
+			continue
+		}
+		if *snapshot.Spec.ID == id {
+			return id, t.checkSnapshotWindow(snapshot)
+		}
+		if strings.HasPrefix(*snapshot.Spec.ID, id) {
+			matches = append(matches, *snapshot.Spec.ID)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		if t.hasSnapshotWindow() {
+			return "", fmt.Errorf("snapshot %s not found in synced snapshots, can't check it was taken %s", id, t.snapshotWindow())
+		}
+		log.Printf("Warning: snapshot %s not found in synced snapshots, passing it to restic as is", id)
+		return id, nil
+	case 1:
+		for _, snapshot := range snapshots {
+			if snapshot.Spec.ID != nil && *snapshot.Spec.ID == matches[0] {
+				return matches[0], t.checkSnapshotWindow(snapshot)
+			}
+		}
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("snapshot id %s is ambiguous, it matches: %s", id, strings.Join(matches, ", "))
+	}
+}
+
+// latestSnapshot returns the ID of the latest snapshot taken within the snapshot window.
+func (t *RestoreTask) latestSnapshot(snapshots []k8upv1.Snapshot) (string, error) {
+	var latest *k8upv1.Snapshot
+	for i, snapshot := range snapshots {
+		if snapshot.Spec.ID == nil || snapshot.Spec.Date == nil || !t.inSnapshotWindow(snapshot.Spec.Date.Time) {
+			continue
+		}
+		// Volumes are backed up in separate snapshots, only snapshots of the filter are candidates.
+		if !snapshotCovers(snapshot, t.Args.RestoreFilter) {
+			continue
+		}
+		if latest == nil || snapshot.Spec.Date.After(latest.Spec.Date.Time) {
+			latest = &snapshots[i]
+		}
+	}
+
+	if latest == nil {
+		if t.hasSnapshotWindow() {
+			return "", fmt.Errorf("no synced snapshot of %s was taken %s", t.Args.RestoreFilter, t.snapshotWindow())
+		}
+		return "", fmt.Errorf("no synced snapshots of %s found", t.Args.RestoreFilter)
+	}
+
+	log.Printf("Latest snapshot %s was taken at %s", *latest.Spec.ID, latest.Spec.Date.UTC().Format(time.RFC3339))
+	return *latest.Spec.ID, nil
+}
+
+// snapshotCovers determines if a snapshot contains files of the restore filter. Snapshots without
+// paths are assumed to contain them.
+func snapshotCovers(snapshot k8upv1.Snapshot, filter string) bool {
+	filter = path.Clean("/" + filter)
+	if snapshot.Spec.Paths == nil || filter == "/" {
+		return true
+	}
+
+	for _, p := range *snapshot.Spec.Paths {
+		p = path.Clean("/" + p)
+		if p == filter || strings.HasPrefix(filter, p+"/") || strings.HasPrefix(p, filter+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRestoreDate parses a date, or an RFC 3339 time, to recover to. It returns the time snapshots
+// must have been taken before, the end of the day (UTC) for dates.
+func ParseRestoreDate(date string) (time.Time, error) {
+	if day, err := time.Parse(time.DateOnly, date); err == nil {
+		return day.AddDate(0, 0, 1), nil
+	}
+
+	at, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid restore date %q, must be a date (2006-01-02) or RFC 3339 time", date)
+	}
+	// Snapshots taken at the time itself are included.
+	return at.Add(time.Nanosecond), nil
+}
+
+// checkSnapshotWindow ensures a snapshot was taken within the snapshot window.
+func (t *RestoreTask) checkSnapshotWindow(snapshot k8upv1.Snapshot) error {
+	if !t.hasSnapshotWindow() {
+		return nil
+	}
+	if snapshot.Spec.Date == nil {
+		return fmt.Errorf("snapshot %s has no date, can't check it was taken %s", *snapshot.Spec.ID, t.snapshotWindow())
+	}
+	if !t.inSnapshotWindow(snapshot.Spec.Date.Time) {
+		return fmt.Errorf("snapshot %s was taken at %s, not %s", *snapshot.Spec.ID, snapshot.Spec.Date.UTC().Format(time.RFC3339), t.snapshotWindow())
+	}
+	return nil
+}
+
+func (t *RestoreTask) hasSnapshotWindow() bool {
+	return !t.SnapshotBefore.IsZero() || !t.SnapshotAfter.IsZero()
+}
+
+func (t *RestoreTask) inSnapshotWindow(date time.Time) bool {
+	if !t.SnapshotBefore.IsZero() && !date.Before(t.SnapshotBefore) {
+		return false
+	}
+	return t.SnapshotAfter.IsZero() || date.After(t.SnapshotAfter)
+}
+
+// snapshotWindow describes the snapshot window for errors.
+func (t *RestoreTask) snapshotWindow() string {
+	var window []string
+	if !t.SnapshotAfter.IsZero() {
+		window = append(window, "after "+t.SnapshotAfter.UTC().Format(time.RFC3339))
+	}
+	if !t.SnapshotBefore.IsZero() {
+		window = append(window, "before "+t.SnapshotBefore.UTC().Format(time.RFC3339))
+	}
+	return strings.Join(window, " and ")
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"testing"
+	"time"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testSnapshot returns a synced k8up snapshot taken at the date of the paths.
+func testSnapshot(id string, date time.Time, paths ...string) *k8upv1.Snapshot {
+	taken := metav1.NewTime(date)
+	return &k8upv1.Snapshot{
+		ObjectMeta: metav1.ObjectMeta{Name: id[:8]},
+		Spec:       k8upv1.SnapshotSpec{ID: &id, Date: &taken, Paths: &paths},
+	}
+}
+
+func TestResolveSnapshot(t *testing.T) {
+	monday := time.Date(2026, 10, 12, 3, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+	wednesday := monday.AddDate(0, 0, 2)
+
+	tests := []struct {
+		name    string
+		id      string
+		filter  string
+		before  time.Time
+		after   time.Time
+		want    string
+		wantErr bool
+	}{
+		{name: "full id", id: "1a2b3c4d5e6f", want: "1a2b3c4d5e6f"},
+		{name: "unique prefix", id: "9f8e", want: "9f8e7d6c5b4a"},
+		{name: "ambiguous prefix", id: "1a2b", wantErr: true},
+		{name: "not synced", id: "0000", want: "0000"},
+		{name: "not synced with window", id: "0000", after: monday, wantErr: true},
+		{name: "in window", id: "1a2b3c4d5e6f", after: monday, want: "1a2b3c4d5e6f"},
+		{name: "outside window", id: "9f8e", before: tuesday, wantErr: true},
+		{name: "latest", id: LatestSnapshot, filter: "/nginx", want: "9f8e7d6c5b4a"},
+		{name: "latest of volume", id: LatestSnapshot, filter: "/mariadb", want: "1a2b9988aabb"},
+		{name: "latest before", id: LatestSnapshot, filter: "/nginx", before: wednesday, want: "1a2b3c4d5e6f"},
+		{name: "latest outside window", id: LatestSnapshot, filter: "/nginx", after: wednesday, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := newFakeTask(t,
+				testSnapshot("1a2b3c4d5e6f", tuesday, "/nginx"),
+				testSnapshot("1a2b9988aabb", tuesday, "/mariadb"),
+				testSnapshot("9f8e7d6c5b4a", wednesday, "/nginx"),
+			)
+			task.Args.RestoreFilter = tt.filter
+			task.SnapshotBefore = tt.before
+			task.SnapshotAfter = tt.after
+
+			got, err := task.ResolveSnapshot(tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveSnapshot() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ResolveSnapshot() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/dustin/go-humanize"
+	"github.com/mholt/archives"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// SparseFilesWarn archives sparse files at full size with a warning.
+	SparseFilesWarn = "warn"
+	// SparseFilesExclude leaves sparse files out of the archive.
+	SparseFilesExclude = "exclude"
+	// SparseFilesFail fails the archive when the restore contains sparse files.
+	SparseFilesFail = "fail"
+)
+
+const (
+	// sparseMinSize is the size from which files are checked for sparseness, smaller files don't
+	// bloat the archive noticeably.
+	sparseMinSize = 64 << 20
+	// sparseSamples is the number of blocks sampled to detect files which are mostly zeros.
+	sparseSamples = 64
+	// sparseBlockSize is the size of the sampled blocks.
+	sparseBlockSize = 4096
+)
+
+// isSparse determines if a large file is sparse, either with holes on disk or mostly zeros. restic
+// only restores holes with `--sparse`, which k8up doesn't pass, so sparse files of the backup are
+// restored as zeros and are detected by sampling evenly spaced blocks instead of reading them whole.
+// The allocated block count isn't used, compressing or deduplicating storage allocates fewer blocks
+// for ordinary files too.
+func isSparse(file archives.FileInfo) (bool, error) {
+	if !file.Mode().IsRegular() || file.Size() < sparseMinSize {
+		return false, nil
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	if osFile, ok := f.(*os.File); ok && holeBytes(osFile, file.Size())*2 > file.Size() {
+		return true, nil
+	}
+
+	r, ok := f.(io.ReaderAt)
+	if !ok {
+		return false, nil
+	}
+
+	zero := make([]byte, sparseBlockSize)
+	block := make([]byte, sparseBlockSize)
+	zeros := 0
+	step := (file.Size() - sparseBlockSize) / (sparseSamples - 1)
+	for i := int64(0); i < sparseSamples; i++ {
+		if _, err := r.ReadAt(block, i*step); err != nil && err != io.EOF {
+			return false, err
+		}
+		if bytes.Equal(block, zero) {
+			zeros++
+		}
+	}
+
+	return zeros*2 > sparseSamples, nil
+}
+
+// holeBytes sums the holes of the file with SEEK_DATA and SEEK_HOLE. Filesystems without hole
+// support report the whole file as data, errors are treated the same.
+func holeBytes(f *os.File, size int64) int64 {
+	fd := int(f.Fd())
+	var holes, offset int64
+	for offset < size {
+		data, err := unix.Seek(fd, offset, unix.SEEK_DATA)
+		if err != nil {
+			// ENXIO means there is no data after offset, the rest of the file is a hole.
+			if err == unix.ENXIO {
+				holes += size - offset
+			}
+			break
+		}
+		holes += data - offset
+		hole, err := unix.Seek(fd, data, unix.SEEK_HOLE)
+		if err != nil {
+			break
+		}
+		offset = hole
+	}
+	return holes
+}
+
+// handleSparseFiles applies the sparse file handling to the files to archive. The tar writer can't
+// encode sparse files, so they are archived at full size: they compress well, but extract to their
+// full size.
+func (t *RestoreTask) handleSparseFiles(files []archives.FileInfo) ([]archives.FileInfo, error) {
+	var handled []archives.FileInfo
+	var sparse int
+	for _, file := range files {
+		ok, err := isSparse(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check %s for sparseness: %w", file.NameInArchive, err)
+		}
+		if !ok {
+			handled = append(handled, file)
+			continue
+		}
+
+		sparse++
+		switch t.SparseFiles {
+		case SparseFilesExclude:
+			log.Printf("Warning: excluding sparse file %s (%s) from the archive", file.NameInArchive, humanize.Bytes(uint64(file.Size())))
+		default:
+			log.Printf("Warning: sparse file %s is archived at its full size of %s and extracts to it", file.NameInArchive, humanize.Bytes(uint64(file.Size())))
+			handled = append(handled, file)
+		}
+	}
+
+	if sparse > 0 && t.SparseFiles == SparseFilesFail {
+		return nil, fmt.Errorf("restore contains %d sparse files, pass -sparse-files warn or exclude to archive the restore", sparse)
+	}
+
+	return handled, nil
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHoleBytes(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(make([]byte, 1<<20)); err != nil {
+		t.Fatal(err)
+	}
+	if holes := holeBytes(f, 1<<20); holes != 0 {
+		t.Errorf("holeBytes() of a written file = %d, want 0", holes)
+	}
+
+	if err := f.Truncate(16 << 20); err != nil {
+		t.Fatal(err)
+	}
+	if holes := holeBytes(f, 16<<20); holes != 0 && holes < 8<<20 {
+		t.Errorf("holeBytes() of a truncated file = %d, want 0 or at least %d", holes, 8<<20)
+	}
+}
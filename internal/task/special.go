@@ -0,0 +1,50 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+
+	"github.com/mholt/archives"
+)
+
+// specialFileModes are file types which can't be meaningfully archived.
+const specialFileModes = fs.ModeDevice | fs.ModeCharDevice | fs.ModeNamedPipe | fs.ModeSocket | fs.ModeIrregular
+
+// handleSpecialFiles leaves device files, sockets and fifos out of the files to archive, logging a
+// warning for each. In strict mode they fail the archive instead.
+func (t *RestoreTask) handleSpecialFiles(files []archives.FileInfo) ([]archives.FileInfo, error) {
+	var regular []archives.FileInfo
+	var special int
+	for _, file := range files {
+		if file.Mode()&specialFileModes == 0 {
+			regular = append(regular, file)
+			continue
+		}
+
+		special++
+		log.Printf("Warning: special file %s (%s) can't be archived", file.NameInArchive, file.Mode().Type())
+	}
+
+	if special > 0 && t.Strict {
+		return nil, fmt.Errorf("restore contains %d special files", special)
+	}
+
+	return regular, nil
+}
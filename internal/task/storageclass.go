@@ -0,0 +1,79 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultStorageClass is the storage class of the restore and archive PVCs, the bulk storage of
+// Lagoon clusters.
+const DefaultStorageClass = "bulk"
+
+// storageClassName returns the storage class of the created PVCs. An empty storage class leaves it
+// unset, so the default storage class of the cluster is used.
+func (t *RestoreTask) storageClassName() *string {
+	if t.StorageClass == "" {
+		return nil
+	}
+	return &t.StorageClass
+}
+
+// storageClassDescription names the storage class of the created PVCs for logs.
+func (t *RestoreTask) storageClassDescription() string {
+	if t.StorageClass == "" {
+		return "cluster default"
+	}
+	return t.StorageClass
+}
+
+// ValidateStorageClass ensures the storage class of the created PVCs exists, PVCs of a missing
+// storage class stay pending and the restore never starts. Service accounts which may not read
+// storage classes skip the check, as does the default storage class of the cluster.
+func (t *RestoreTask) ValidateStorageClass() error {
+	if t.StorageClass == "" {
+		return nil
+	}
+
+	var storageClass storagev1.StorageClass
+	err := t.Client.Get(t.Ctx, client.ObjectKey{Name: t.StorageClass}, &storageClass)
+	switch {
+	case err == nil:
+		return nil
+	case apierrors.IsForbidden(err):
+		log.Printf("Warning: can't check storage class %s exists: %v", t.StorageClass, err)
+		return nil
+	case !apierrors.IsNotFound(err):
+		return fmt.Errorf("failed to get storage class %s: %w", t.StorageClass, err)
+	}
+
+	var storageClasses storagev1.StorageClassList
+	if err := t.Client.List(t.Ctx, &storageClasses); err != nil || len(storageClasses.Items) == 0 {
+		return fmt.Errorf("storage class %s not found, pass an existing one with -storage-class", t.StorageClass)
+	}
+	var names []string
+	for _, sc := range storageClasses.Items {
+		names = append(names, sc.Name)
+	}
+	return fmt.Errorf("storage class %s not found, pass one of %s with -storage-class", t.StorageClass, strings.Join(names, ", "))
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+
+	"github.com/mholt/archives"
+)
+
+const (
+	// SymlinksPreserve archives symlinks as links.
+	SymlinksPreserve = "preserve"
+	// SymlinksFollow archives the contents of symlink targets within the restore.
+	SymlinksFollow = "follow"
+	// SymlinksSkip leaves symlinks out of the archive.
+	SymlinksSkip = "skip"
+)
+
+// linkOutsideRoot determines if a symlink at path points outside of root.
+func linkOutsideRoot(root string, path string, target string) bool {
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	return !isWithin(filepath.Clean(target), filepath.Clean(root))
+}
+
+// handleSymlinks applies the symlink handling to the files to archive. Symlinks pointing outside of
+// the restore are flagged when preserved, and never followed since they resolve to files of the
+// upload pod rather than the restore.
+func (t *RestoreTask) handleSymlinks(ctx context.Context, root string, files []archives.FileInfo) ([]archives.FileInfo, error) {
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve restore target: %w", err)
+	}
+
+	var handled []archives.FileInfo
+	for _, file := range files {
+		if file.Mode()&fs.ModeSymlink == 0 {
+			handled = append(handled, file)
+			continue
+		}
+
+		path := filepath.Join(root, file.NameInArchive)
+		outside := linkOutsideRoot(root, path, file.LinkTarget)
+
+		switch t.Symlinks {
+		case SymlinksSkip:
+			log.Printf("Warning: skipping symlink %s -> %s", file.NameInArchive, file.LinkTarget)
+		case SymlinksFollow:
+			// Resolve link chains, the final target must be within the restore too.
+			resolved, err := filepath.EvalSymlinks(path)
+			if err == nil && !outside {
+				outside = !isWithin(resolved, resolvedRoot)
+			}
+			if outside {
+				log.Printf("Warning: skipping symlink %s -> %s pointing outside the restore", file.NameInArchive, file.LinkTarget)
+				continue
+			}
+			if err != nil {
+				log.Printf("Warning: skipping broken symlink %s -> %s", file.NameInArchive, file.LinkTarget)
+				continue
+			}
+			target, err := archives.FilesFromDisk(ctx, nil, map[string]string{
+				resolved: file.NameInArchive,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to follow symlink %s: %w", file.NameInArchive, err)
+			}
+			handled = append(handled, target...)
+		default:
+			if outside {
+				log.Printf("Warning: symlink %s -> %s points outside the restore", file.NameInArchive, file.LinkTarget)
+			}
+			handled = append(handled, file)
+		}
+	}
+
+	return handled, nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"context"
+	"testing"
+
+	k8upv1 "github.com/k8up-io/k8up/v2/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// testNamespace is the environment namespace of tasks with a fake client.
+const testNamespace = "project-main"
+
+// testSchedule returns the k8up Schedule of an environment backing up to an S3 bucket.
+func testSchedule() *k8upv1.Schedule {
+	secretKey := func(key string) *corev1.SecretKeySelector {
+		return &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "baas-repo-pw"}, Key: key}
+	}
+	return &k8upv1.Schedule{
+		ObjectMeta: metav1.ObjectMeta{Name: scheduleName},
+		Spec: k8upv1.ScheduleSpec{
+			Backend: &k8upv1.Backend{
+				RepoPasswordSecretRef: secretKey("repo-pw"),
+				S3: &k8upv1.S3Spec{
+					Endpoint:                 "https://s3.example.com",
+					Bucket:                   "baas-project",
+					AccessKeyIDSecretRef:     secretKey("access-key-id"),
+					SecretAccessKeySecretRef: secretKey("secret-access-key"),
+				},
+			},
+		},
+	}
+}
+
+// newFakeTask returns a task with the defaults of NewRestoreTask whose clients are backed by a fake
+// client holding the objects, which are put in the environment namespace.
+func newFakeTask(t *testing.T, objs ...client.Object) *RestoreTask {
+	t.Helper()
+
+	clientScheme := k8runtime.NewScheme()
+	if err := scheme.AddToScheme(clientScheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := k8upv1.AddToScheme(clientScheme); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, obj := range objs {
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(testNamespace)
+		}
+	}
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(clientScheme).
+		WithRESTMapper(testrestmapper.TestOnlyStaticRESTMapper(clientScheme)).
+		WithObjects(objs...).
+		WithStatusSubresource(&k8upv1.Restore{}).
+		Build()
+	namespaceClient := client.NewNamespacedClient(fakeClient, testNamespace)
+
+	return &RestoreTask{
+		Args:            TaskArgs{BackupId: "1a2b3c4d", RestoreFilter: "/nginx"},
+		Ctx:             context.Background(),
+		Client:          namespaceClient,
+		ClusterClient:   fakeClient,
+		SourceClient:    namespaceClient,
+		Namespace:       testNamespace,
+		SourceNamespace: testNamespace,
+		TaskId:          "127",
+		ResourcePrefix:  DefaultResourcePrefix,
+		TaskKey:         DefaultResourcePrefix + "-127",
+		OnEmpty:         OnEmptyFail,
+		OutputFormat:    OutputFormatArchive,
+		StorageClass:    DefaultStorageClass,
+		PVCSize:         DefaultPVCSize,
+		LookupBackoff:   DefaultLookupBackoff,
+		CleanupBackoff:  DefaultCleanupBackoff,
+		RestoreMethods:  []string{RestoreMethodFolder},
+		Symlinks:        SymlinksPreserve,
+		ArchiveUID:      -1,
+		ArchiveGID:      -1,
+		ExpectedFiles:   -1,
+	}
+}
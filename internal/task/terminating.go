@@ -0,0 +1,130 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultPVCTerminationTimeout is how long to wait for a terminating PVC of a previous run.
+const DefaultPVCTerminationTimeout = 2 * time.Minute
+
+// waitForTerminatingPVC waits until a PVC of a previous run with the same name, stuck terminating
+// on a finalizer, is gone. When it is still terminating after the timeout its finalizers are
+// removed with Force, unless pods still mount it.
+func (t *RestoreTask) waitForTerminatingPVC(name string) error {
+	var pvc corev1.PersistentVolumeClaim
+	err := t.Client.Get(t.Ctx, client.ObjectKey{Name: name}, &pvc)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get pvc %s: %w", name, err)
+	}
+	if pvc.DeletionTimestamp == nil {
+		return nil
+	}
+
+	pods, err := t.podsMountingPVC(name)
+	if err != nil {
+		return err
+	}
+	blockers := fmt.Sprintf("finalizers: %s, mounted by pods: %s", listOrNone(pvc.Finalizers), listOrNone(pods))
+	log.Printf("Waiting up to %s for pvc %s of a previous run to terminate (%s)", t.PVCTerminationTimeout, name, blockers)
+
+	if err := t.waitForPVCGone(name, t.PVCTerminationTimeout); err == nil {
+		return nil
+	} else if t.Ctx.Err() != nil {
+		return err
+	}
+
+	if !t.Force {
+		return fmt.Errorf("pvc %s is stuck terminating (%s), remove the blockers or pass -force to remove its finalizers", name, blockers)
+	}
+
+	// Removing the protection finalizer of a mounted PVC would pull the volume from under the pods.
+	pods, err = t.podsMountingPVC(name)
+	if err != nil {
+		return err
+	}
+	if len(pods) > 0 {
+		return fmt.Errorf("pvc %s is stuck terminating and still mounted by pods %s, its finalizers can't be removed safely", name, strings.Join(pods, ", "))
+	}
+
+	if err := t.Client.Get(t.Ctx, client.ObjectKey{Name: name}, &pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get pvc %s: %w", name, err)
+	}
+	patch := client.MergeFrom(pvc.DeepCopy())
+	pvc.Finalizers = nil
+	if err := t.Client.Patch(t.Ctx, &pvc, patch); err != nil {
+		return fmt.Errorf("failed to remove finalizers of pvc %s: %w", name, err)
+	}
+	log.Printf("Removed finalizers of terminating pvc %s", name)
+
+	return t.waitForPVCGone(name, 30*time.Second)
+}
+
+// waitForPVCGone waits until the PVC is removed.
+func (t *RestoreTask) waitForPVCGone(name string, timeout time.Duration) error {
+	return wait.PollUntilContextTimeout(t.Ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		var pvc corev1.PersistentVolumeClaim
+		err := t.Client.Get(ctx, client.ObjectKey{Name: name}, &pvc)
+		return apierrors.IsNotFound(err), nil
+	})
+}
+
+// podsMountingPVC lists the names of pods that have not finished and mount the PVC.
+func (t *RestoreTask) podsMountingPVC(name string) ([]string, error) {
+	var pods corev1.PodList
+	if err := t.Client.List(t.Ctx, &pods); err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var mounting []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == name {
+				mounting = append(mounting, pod.Name)
+				break
+			}
+		}
+	}
+
+	return mounting, nil
+}
+
+func listOrNone(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
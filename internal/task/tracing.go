@@ -0,0 +1,129 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the task phases. It is a no-op until tracing is set up.
+var tracer = otel.Tracer("github.com/amazeeio/lagoon-restore-files-task")
+
+// SetupTracing exports spans of the task phases to an OTLP/HTTP endpoint, eg
+// `http://otel-collector:4318`. Without an endpoint tracing is a no-op. The returned function
+// flushes and stops the exporter.
+func SetupTracing(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName("restore-files-task"),
+		semconv.ServiceVersion(TaskVersion),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// spanContext returns the context carrying the span of the current phase.
+func (t *RestoreTask) spanContext() context.Context {
+	if t.spanCtx == nil {
+		return t.Ctx
+	}
+	return t.spanCtx
+}
+
+// StartSpan starts a span for a phase of the task. The span is the parent of the spans started
+// until the returned function ends it, recording the error of the phase if any. The task context
+// isn't changed, as goroutines of the task read it meanwhile.
+func (t *RestoreTask) StartSpan(name string, attrs ...attribute.KeyValue) func(err error) {
+	parent := t.spanCtx
+	ctx, span := tracer.Start(t.spanContext(), name, trace.WithAttributes(attrs...))
+	t.spanCtx = ctx
+
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		t.spanCtx = parent
+	}
+}
+
+// Trace runs a phase of the task in a span.
+func (t *RestoreTask) Trace(name string, f func() error, attrs ...attribute.KeyValue) error {
+	end := t.StartSpan(name, attrs...)
+	err := f()
+	end(err)
+	return err
+}
+
+// SetSpanAttributes adds attributes to the span of the current phase.
+func (t *RestoreTask) SetSpanAttributes(attrs ...attribute.KeyValue) {
+	trace.SpanFromContext(t.spanContext()).SetAttributes(attrs...)
+}
+
+// Phase is a timed phase of the upload pod, reported to the parent task to trace it.
+type Phase struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// TimePhase runs a phase of the upload pod and records its timing.
+func TimePhase(phases *[]Phase, name string, f func() error) error {
+	start := time.Now()
+	err := f()
+	*phases = append(*phases, Phase{Name: name, Start: start, End: time.Now()})
+	return err
+}
+
+// TracePhases records the phases reported by the upload pod as spans of the current phase.
+func (t *RestoreTask) TracePhases(phases []Phase) {
+	for _, phase := range phases {
+		_, span := tracer.Start(t.spanContext(), phase.Name, trace.WithTimestamp(phase.Start))
+		span.SetAttributes(attribute.Float64("duration_seconds", phase.End.Sub(phase.Start).Seconds()))
+		span.End(trace.WithTimestamp(phase.End))
+	}
+}
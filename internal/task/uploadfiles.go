@@ -0,0 +1,197 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	lclient "github.com/uselagoon/machinery/api/lagoon/client"
+)
+
+// DefaultMaxUploadFiles is the default number of files uploaded individually, MaxUploadFilesLimit
+// the most that can be configured.
+const (
+	DefaultMaxUploadFiles = 10
+	MaxUploadFilesLimit   = 100
+)
+
+const (
+	// UploadAtomicityBestEffort keeps the files uploaded before an upload failed.
+	UploadAtomicityBestEffort = "best-effort"
+	// UploadAtomicityAllOrNothing stops at the first failed upload and removes the files already
+	// uploaded to the Lagoon tasks.
+	UploadAtomicityAllOrNothing = "all-or-nothing"
+)
+
+// ValidateUploadFiles checks the pattern selecting files to upload individually is a valid glob.
+func ValidateUploadFiles(pattern string) error {
+	if strings.Trim(pattern, "/") == "" {
+		return fmt.Errorf("a pattern is required to select the files to upload")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid upload files pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// matchesUploadFiles determines if a path relative to the restore root matches the upload files
+// pattern. Patterns with a `/` match the path from the snapshot root, others the file name.
+func (t *RestoreTask) matchesUploadFiles(name string) bool {
+	pattern := strings.TrimPrefix(t.UploadFiles, "/")
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(name)
+	}
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
+
+// SelectUploadFiles returns the restored regular files matching the upload files pattern, relative
+// to the restore target. Excluded files are not selected. It fails when more than MaxUploadFiles
+// match, an archive is the better fit then.
+func (t *RestoreTask) SelectUploadFiles(restoreTarget string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(restoreTarget, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(restoreTarget, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+
+		if (t.ExcludeHidden && t.isHidden(rel)) || t.isExcludedPath(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || t.isExcludedExt(rel, false) || !t.matchesUploadFiles(rel) {
+			return nil
+		}
+
+		files = append(files, rel)
+		if len(files) > t.MaxUploadFiles {
+			return fmt.Errorf("more than %d files match %s, narrow the pattern or upload an archive", t.MaxUploadFiles, t.UploadFiles)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// LinkUploadFiles links the selected files into dir, named after their path with `/` replaced by
+// `_`, so files with the same name in different directories don't overwrite each other on the
+// Lagoon task. It returns the links to upload.
+func LinkUploadFiles(restoreTarget string, dir string, files []string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var links []string
+	for _, file := range files {
+		link := filepath.Join(dir, strings.ReplaceAll(file, "/", "_"))
+		if err := os.Symlink(filepath.Join(restoreTarget, file), link); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+
+	return links, nil
+}
+
+// deleteTaskFiles removes all files of a Lagoon task, the Lagoon API can't remove single files. It
+// must only be called for tasks which had no files before this run uploaded to them.
+func deleteTaskFiles(ctx context.Context, lc *lclient.Client, id string) error {
+	taskId, _ := strconv.Atoi(id)
+	_, err := lc.ProcessRaw(ctx, `mutation ($id: Int!) { deleteFilesForTask(input: {id: $id}) }`, map[string]interface{}{
+		"id": taskId,
+	})
+	return err
+}
+
+// taskFiles returns the number of files of a Lagoon task.
+func taskFiles(ctx context.Context, lc *lclient.Client, id string) (int, error) {
+	taskId, _ := strconv.Atoi(id)
+	raw, err := lc.ProcessRaw(ctx, `query ($id: Int!) { taskById(id: $id) { files { filename } } }`, map[string]interface{}{
+		"id": taskId,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return 0, err
+	}
+	var result struct {
+		TaskByID struct {
+			Files []struct {
+				Filename string `json:"filename"`
+			} `json:"files"`
+		} `json:"taskById"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return 0, err
+	}
+	return len(result.TaskByID.Files), nil
+}
+
+// tasksWithFiles returns the task and additional tasks which have files before the upload. Tasks
+// whose files can't be read are assumed to have files, so they are never rolled back.
+func (t *RestoreTask) tasksWithFiles(ctx context.Context, lc *lclient.Client) map[string]bool {
+	hadFiles := map[string]bool{}
+	for _, id := range append([]string{t.TaskId}, t.AdditionalTaskIds...) {
+		count, err := taskFiles(ctx, lc, id)
+		if err != nil {
+			log.Printf("Warning: failed to get the files of Lagoon task %s, its uploads won't be rolled back: %v", id, err)
+		}
+		hadFiles[id] = err != nil || count > 0
+	}
+	return hadFiles
+}
+
+// rollbackTaskIds splits the tasks a failed all-or-nothing upload uploaded to into those whose files
+// are removed, and those which had files before, which would be removed as well.
+func rollbackTaskIds(ids []string, uploadedTo map[string]bool, hadFiles map[string]bool) (remove []string, keep []string) {
+	for _, id := range ids {
+		switch {
+		case !uploadedTo[id]:
+		case hadFiles[id]:
+			keep = append(keep, id)
+		default:
+			remove = append(remove, id)
+		}
+	}
+	return remove, keep
+}
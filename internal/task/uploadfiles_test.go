@@ -0,0 +1,57 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRollbackTaskIds(t *testing.T) {
+	tests := []struct {
+		name       string
+		uploadedTo []string
+		hadFiles   []string
+		remove     []string
+		keep       []string
+	}{
+		{name: "nothing uploaded"},
+		{name: "own task", uploadedTo: []string{"127"}, remove: []string{"127"}},
+		{name: "all tasks", uploadedTo: []string{"127", "128", "129"}, remove: []string{"127", "128", "129"}},
+		{name: "additional task not uploaded to", uploadedTo: []string{"127", "128"}, remove: []string{"127", "128"}},
+		{name: "additional task with files", uploadedTo: []string{"127", "128", "129"}, hadFiles: []string{"128"}, remove: []string{"127", "129"}, keep: []string{"128"}},
+		{name: "own task with files of a previous attempt", uploadedTo: []string{"127"}, hadFiles: []string{"127"}, keep: []string{"127"}},
+		{name: "task with files not uploaded to", uploadedTo: []string{"127"}, hadFiles: []string{"129"}, remove: []string{"127"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploadedTo, hadFiles := map[string]bool{}, map[string]bool{}
+			for _, id := range tt.uploadedTo {
+				uploadedTo[id] = true
+			}
+			for _, id := range tt.hadFiles {
+				hadFiles[id] = true
+			}
+
+			remove, keep := rollbackTaskIds([]string{"127", "128", "129"}, uploadedTo, hadFiles)
+			if !slices.Equal(remove, tt.remove) || !slices.Equal(keep, tt.keep) {
+				t.Errorf("rollbackTaskIds() = %v, %v, want %v, %v", remove, keep, tt.remove, tt.keep)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mholt/archives"
+)
+
+// VerifyArchive reads back an archive of the archive format completely, so corruption while
+// compressing or writing it, eg disk errors or truncation, is caught before it is uploaded. Reading
+// the compressed stream to its end verifies its checksum, reading every entry verifies the tar
+// structure. Zip entries are verified against their CRC. It returns the number of entries in the
+// archive.
+func VerifyArchive(name string, format string) (int, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if format == ArchiveFormatZip {
+		return verifyZip(f)
+	}
+
+	var decompressor archives.Decompressor
+	switch format {
+	case ArchiveFormatTarZst:
+		decompressor = archives.Zstd{}
+	case ArchiveFormatTarXz:
+		decompressor = archives.Xz{}
+	case ArchiveFormatTarBz2:
+		decompressor = archives.Bz2{}
+	default:
+		decompressor = archives.Gz{}
+	}
+
+	zr, err := decompressor.OpenReader(f)
+	if err != nil {
+		return 0, fmt.Errorf("invalid compressed stream: %w", err)
+	}
+	defer zr.Close()
+
+	var entries int
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return entries, fmt.Errorf("invalid tar entry after %d entries: %w", entries, err)
+		}
+		if _, err := io.Copy(io.Discard, tr); err != nil {
+			return entries, fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+		entries++
+	}
+
+	// The tar end marker may be followed by padding, the checksum is only checked at the end of the
+	// compressed stream.
+	if _, err := io.Copy(io.Discard, zr); err != nil {
+		return entries, fmt.Errorf("invalid compressed stream: %w", err)
+	}
+
+	return entries, nil
+}
+
+// verifyZip reads every entry of a zip archive, which verifies their CRC.
+func verifyZip(f *os.File) (int, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return 0, fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	for i, file := range zr.File {
+		rc, err := file.Open()
+		if err != nil {
+			return i, fmt.Errorf("failed to open %s: %w", file.Name, err)
+		}
+		_, err = io.Copy(io.Discard, rc)
+		rc.Close()
+		if err != nil {
+			return i, fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+	}
+
+	return len(zr.File), nil
+}
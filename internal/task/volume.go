@@ -0,0 +1,57 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// volumeBackupRoot is the path k8up backs up PVCs under, each PVC at /data/{pvc name}.
+const volumeBackupRoot = "/data"
+
+// ParseVolumeMap parses NAME=PATH pairs mapping volume names to their restic path.
+func ParseVolumeMap(pairs []string) (map[string]string, error) {
+	volumes := map[string]string{}
+	for _, pair := range pairs {
+		name, p, ok := strings.Cut(pair, "=")
+		if !ok || name == "" || !path.IsAbs(p) {
+			return nil, fmt.Errorf("invalid volume mapping %q, must be NAME=/absolute/path", pair)
+		}
+		volumes[name] = path.Clean(p)
+	}
+	return volumes, nil
+}
+
+// VolumeRestoreFilter builds the restore filter of a path within a named volume. Volumes are backed
+// up at /data/{volume} by convention, unless mapped to another restic path.
+func VolumeRestoreFilter(volume string, filter string, volumes map[string]string) (string, error) {
+	root, ok := volumes[volume]
+	if !ok {
+		if errs := validation.IsDNS1123Label(volume); len(errs) > 0 {
+			return "", fmt.Errorf("invalid volume name %q: %s", volume, strings.Join(errs, ", "))
+		}
+		root = path.Join(volumeBackupRoot, volume)
+	}
+
+	// The filter is relative to the volume.
+	rel := path.Clean("/" + filter)
+	return path.Join(root, rel), nil
+}
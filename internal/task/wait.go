@@ -0,0 +1,146 @@
+/*
+Copyright 2025 amazee.io
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package task
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// WaitModeWatch watches resources, falling back to polling when watching is forbidden.
+	WaitModeWatch = "watch"
+	// WaitModePoll polls resources, for clusters where the task may only get and list resources.
+	WaitModePoll = "poll"
+)
+
+// DefaultPollInterval is the default interval between gets of polled resources.
+const DefaultPollInterval = 5 * time.Second
+
+// DefaultRestoreTimeout and DefaultUploadPodTimeout are the default timeouts of waiting for the
+// restore and the upload pod.
+const (
+	DefaultRestoreTimeout   = 2 * time.Hour
+	DefaultUploadPodTimeout = 2 * time.Hour
+)
+
+// waitLogInterval is the interval of logging the time left until a wait times out.
+const waitLogInterval = 10 * time.Minute
+
+// ErrWaitTimeout is returned when a restore or pod did not finish within its timeout.
+var ErrWaitTimeout = errors.New("timed out")
+
+// stopAfter stops the watch when the timeout elapses or the task is cancelled, logging the time left
+// every waitLogInterval. No timeout applies when it is zero. The returned context is done when the
+// watch was stopped, cancel it when the wait ends.
+func (t *RestoreTask) stopAfter(w watch.Interface, what string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(t.Ctx)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(t.Ctx, timeout)
+	}
+	deadline, hasDeadline := ctx.Deadline()
+
+	go func() {
+		ticker := time.NewTicker(waitLogInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if hasDeadline {
+					log.Printf("Waiting for %s, %s left until it times out", what, time.Until(deadline).Round(time.Second))
+				}
+			case <-ctx.Done():
+				w.Stop()
+				return
+			}
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// watchObject watches changes of an object until the watch is stopped. In poll mode or when
+// watching is forbidden, the object is polled instead and every get is sent as a modified event.
+func (t *RestoreTask) watchObject(obj client.Object, list client.ObjectList) (watch.Interface, error) {
+	if t.WaitMode == WaitModePoll {
+		return t.pollObject(obj), nil
+	}
+
+	w, err := t.watchWithRetry(list, &client.ListOptions{
+		Namespace:     obj.GetNamespace(),
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", obj.GetName()),
+	})
+	if apierrors.IsForbidden(err) {
+		log.Printf("Warning: watching %s is forbidden, polling every %s instead: %v", obj.GetName(), t.PollInterval, err)
+		return t.pollObject(obj), nil
+	}
+	return w, err
+}
+
+// pollObject gets the object every poll interval until stopped or the task is cancelled, retrying
+// transient errors on the next poll. The result channel is closed when the object can't be get.
+func (t *RestoreTask) pollObject(obj client.Object) watch.Interface {
+	events := make(chan watch.Event)
+	w := watch.NewProxyWatcher(events)
+
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(t.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			polled := obj.DeepCopyObject().(client.Object)
+			err := t.Client.Get(t.Ctx, client.ObjectKeyFromObject(obj), polled)
+			switch {
+			case err == nil:
+				select {
+				case events <- watch.Event{Type: watch.Modified, Object: polled}:
+				case <-w.StopChan():
+					return
+				case <-t.Ctx.Done():
+					return
+				}
+			case t.Ctx.Err() != nil:
+				return
+			case IsTransient(err):
+				log.Printf("Retrying poll of %s: %v", obj.GetName(), err)
+			default:
+				log.Printf("Failed to poll %s: %v", obj.GetName(), err)
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-w.StopChan():
+				return
+			case <-t.Ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return w
+}